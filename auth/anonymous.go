@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// maxNicknameLength of anonymous Players
+const maxNicknameLength = 32
+
+// Anonymous accepts every token, for development and load tests only.
+// The token is used as nickname
+type Anonymous struct{}
+
+// NewAnonymous ...
+func NewAnonymous() *Anonymous {
+	return &Anonymous{}
+}
+
+// Authorize Player without verification
+func (a *Anonymous) Authorize(token string, player *model.Player) error {
+	player.GlobalID = fmt.Sprintf("anonymous-%d", player.ID)
+	player.Nickname = token
+	if len(player.Nickname) > maxNicknameLength {
+		player.Nickname = player.Nickname[:maxNicknameLength]
+	}
+	if player.Nickname == "" {
+		player.Nickname = fmt.Sprintf("Player %d", player.ID)
+	}
+	return nil
+}
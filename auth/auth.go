@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/awdng/triebwerk"
+	"github.com/awdng/triebwerk/model"
+)
+
+// Providers selectable with the AUTH_PROVIDER config
+const (
+	ProviderMaster    = "master"
+	ProviderFirebase  = "firebase"
+	ProviderJWT       = "jwt"
+	ProviderAnonymous = "anonymous"
+)
+
+// ErrUnauthorized is returned for tokens that are invalid or expired
+var ErrUnauthorized = errors.New("auth: unauthorized")
+
+// Authenticator verifies the token a Player sends after connecting and sets its GlobalID and Nickname
+type Authenticator interface {
+	Authorize(token string, player *model.Player) error
+}
+
+// New creates the Authenticator selected by the config
+func New(config triebwerk.Config, firebase *triebwerk.Firebase, master PlayerAuthorizer) (Authenticator, error) {
+	switch strings.ToLower(config.AuthProvider) {
+	case ProviderMaster, "":
+		if master == nil {
+			return nil, errors.New("auth: master server provider needs a master server")
+		}
+		return NewMasterServer(master), nil
+	case ProviderFirebase:
+		if firebase == nil || firebase.App == nil {
+			return nil, errors.New("auth: firebase provider needs a firebase app")
+		}
+		return NewFirebase(firebase), nil
+	case ProviderJWT:
+		return newJWTFromConfig(config)
+	case ProviderAnonymous:
+		return NewAnonymous(), nil
+	}
+	return nil, fmt.Errorf("auth: unknown provider %s", config.AuthProvider)
+}
+
+func newJWTFromConfig(config triebwerk.Config) (Authenticator, error) {
+	switch {
+	case config.AuthJWTSecret != "":
+		return NewHMAC([]byte(config.AuthJWTSecret), config.AuthJWTIssuer), nil
+	case config.AuthJWTPublicKey != "":
+		pem, err := ioutil.ReadFile(config.AuthJWTPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSA(pem, config.AuthJWTIssuer)
+	}
+	return nil, errors.New("auth: jwt provider needs AUTH_JWT_SECRET or AUTH_JWT_PUBLIC_KEY")
+}
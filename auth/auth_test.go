@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/awdng/triebwerk"
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMasterServer struct {
+	token string
+}
+
+func (m *testMasterServer) AuthorizePlayer(token string, player *model.Player) error {
+	m.token = token
+	player.GlobalID = "global"
+	return nil
+}
+
+func TestNewSelectsProvider(t *testing.T) {
+	master := &testMasterServer{}
+	authenticator, err := New(triebwerk.Config{AuthProvider: ProviderMaster}, nil, master)
+	assert.Nil(t, err)
+	player := model.NewPlayer(1, 0, 0, nil)
+	assert.Nil(t, authenticator.Authorize("token", player))
+	assert.Equal(t, "token", master.token)
+	assert.Equal(t, "global", player.GlobalID)
+
+	authenticator, err = New(triebwerk.Config{AuthProvider: ProviderJWT, AuthJWTSecret: "secret"}, nil, master)
+	assert.Nil(t, err)
+	assert.IsType(t, &JWT{}, authenticator)
+
+	authenticator, err = New(triebwerk.Config{AuthProvider: ProviderAnonymous}, nil, nil)
+	assert.Nil(t, err)
+	assert.IsType(t, &Anonymous{}, authenticator)
+
+	for _, config := range []triebwerk.Config{
+		{AuthProvider: "unknown"},
+		{AuthProvider: ProviderFirebase},
+		{AuthProvider: ProviderJWT},
+	} {
+		_, err = New(config, nil, master)
+		assert.NotNil(t, err, config.AuthProvider)
+	}
+}
+
+func TestAnonymous(t *testing.T) {
+	player := model.NewPlayer(3, 0, 0, nil)
+	assert.Nil(t, NewAnonymous().Authorize("", player))
+	assert.Equal(t, "anonymous-3", player.GlobalID)
+	assert.Equal(t, "Player 3", player.Nickname)
+}
+
+func TestFirebaseClaims(t *testing.T) {
+	player := model.NewPlayer(1, 0, 0, nil)
+	assert.Nil(t, authorizeClaims("uid", map[string]interface{}{"name": "tank", "email_verified": true}, player))
+	assert.Equal(t, "uid", player.GlobalID)
+	assert.Equal(t, "tank", player.Nickname)
+
+	for name, claims := range map[string]map[string]interface{}{
+		"unverified email":       {"email_verified": false},
+		"malformed verification": {"email_verified": "true"},
+		"malformed name":         {"name": 42},
+	} {
+		player := model.NewPlayer(1, 0, 0, nil)
+		err := authorizeClaims("uid", claims, player)
+		assert.True(t, errors.Is(err, ErrUnauthorized), name)
+		assert.Equal(t, "", player.GlobalID, name)
+	}
+}
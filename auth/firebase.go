@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/awdng/triebwerk"
+	"github.com/awdng/triebwerk/model"
+)
+
+// firebaseTimeout limits the verification of a token, it may fetch keys and the revocation state
+const firebaseTimeout = 5 * time.Second
+
+// Firebase verifies Firebase ID tokens
+type Firebase struct {
+	firebase *triebwerk.Firebase
+}
+
+// NewFirebase ...
+func NewFirebase(firebase *triebwerk.Firebase) *Firebase {
+	return &Firebase{
+		firebase: firebase,
+	}
+}
+
+// Authorize Player
+func (f *Firebase) Authorize(token string, player *model.Player) error {
+	ctx, cancel := context.WithTimeout(context.Background(), firebaseTimeout)
+	defer cancel()
+	client, err := f.firebase.App.Auth(ctx)
+	if err != nil {
+		return err
+	}
+
+	checkedToken, err := client.VerifyIDTokenAndCheckRevoked(ctx, token)
+	if err != nil {
+		return err
+	}
+	return authorizeClaims(checkedToken.UID, checkedToken.Claims, player)
+}
+
+// authorizeClaims of a verified token, tokens with malformed claims or of users that did not
+// verify their email are rejected
+func authorizeClaims(uid string, claims map[string]interface{}, player *model.Player) error {
+	if claim, ok := claims["email_verified"]; ok {
+		verified, ok := claim.(bool)
+		if !ok {
+			return fmt.Errorf("%w: malformed email_verified claim", ErrUnauthorized)
+		}
+		if !verified {
+			return fmt.Errorf("%w: user email not verified", ErrUnauthorized)
+		}
+	}
+	nickname := player.Nickname
+	if claim, ok := claims["name"]; ok {
+		name, ok := claim.(string)
+		if !ok {
+			return fmt.Errorf("%w: malformed name claim", ErrUnauthorized)
+		}
+		nickname = name
+	}
+
+	player.GlobalID = uid
+	player.Nickname = nickname
+	return nil
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// Claims of a JWT the game server reads
+type Claims struct {
+	Subject   string `json:"sub"`
+	Name      string `json:"name,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// JWT verifies signed JSON Web Tokens locally, signed with HS256 or RS256.
+// The subject becomes the GlobalID and the name the Nickname of the Player
+type JWT struct {
+	algorithm string
+	secret    []byte
+	publicKey *rsa.PublicKey
+	issuer    string
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewHMAC creates a JWT Authenticator for HS256 tokens, issuer is checked if not empty
+func NewHMAC(secret []byte, issuer string) *JWT {
+	return &JWT{
+		algorithm: "HS256",
+		secret:    secret,
+		issuer:    issuer,
+		now:       time.Now,
+	}
+}
+
+// NewRSA creates a JWT Authenticator for RS256 tokens from a PEM encoded public key
+func NewRSA(publicKeyPEM []byte, issuer string) (*JWT, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return nil, errors.New("auth: no PEM encoded public key found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("auth: public key is not an RSA key")
+	}
+	return &JWT{
+		algorithm: "RS256",
+		publicKey: publicKey,
+		issuer:    issuer,
+		now:       time.Now,
+	}, nil
+}
+
+// Authorize Player with a JWT
+func (j *JWT) Authorize(token string, player *model.Player) error {
+	claims, err := j.Verify(token)
+	if err != nil {
+		return err
+	}
+	player.GlobalID = claims.Subject
+	player.Nickname = claims.Name
+	return nil
+}
+
+// Verify the signature and claims of a token
+func (j *JWT) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrUnauthorized)
+	}
+
+	header := jwtHeader{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	// the algorithm is fixed by the config, never by the token
+	if header.Algorithm != j.algorithm {
+		return nil, fmt.Errorf("%w: unexpected algorithm %s", ErrUnauthorized, header.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrUnauthorized)
+	}
+	if !j.verifySignature(parts[0]+"."+parts[1], signature) {
+		return nil, fmt.Errorf("%w: invalid signature", ErrUnauthorized)
+	}
+
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, err
+	}
+	now := j.now().Unix()
+	if claims.ExpiresAt != 0 && now >= claims.ExpiresAt {
+		return nil, fmt.Errorf("%w: token expired", ErrUnauthorized)
+	}
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return nil, fmt.Errorf("%w: token not valid yet", ErrUnauthorized)
+	}
+	if j.issuer != "" && claims.Issuer != j.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %s", ErrUnauthorized, claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrUnauthorized)
+	}
+	return claims, nil
+}
+
+func (j *JWT) verifySignature(signed string, signature []byte) bool {
+	switch j.algorithm {
+	case "HS256":
+		mac := hmac.New(sha256.New, j.secret)
+		mac.Write([]byte(signed))
+		return hmac.Equal(signature, mac.Sum(nil))
+	case "RS256":
+		digest := sha256.Sum256([]byte(signed))
+		return rsa.VerifyPKCS1v15(j.publicKey, crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", ErrUnauthorized)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: malformed token", ErrUnauthorized)
+	}
+	return nil
+}
+
+// SignHMAC creates an HS256 token, e.g. for load tests against a server with the jwt provider
+func SignHMAC(secret []byte, claims Claims) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: "HS256", Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
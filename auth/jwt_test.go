@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHMAC(t *testing.T) {
+	authenticator := NewHMAC([]byte("secret"), "master")
+	now := time.Unix(1500000000, 0)
+	authenticator.now = func() time.Time { return now }
+
+	token, err := SignHMAC([]byte("secret"), Claims{Subject: "user", Name: "tank", Issuer: "master", ExpiresAt: now.Add(time.Minute).Unix()})
+	assert.Nil(t, err)
+	player := model.NewPlayer(1, 0, 0, nil)
+	assert.Nil(t, authenticator.Authorize(token, player))
+	assert.Equal(t, "user", player.GlobalID)
+	assert.Equal(t, "tank", player.Nickname)
+
+	invalid := map[string]Claims{
+		"expired":      {Subject: "user", Issuer: "master", ExpiresAt: now.Unix()},
+		"not valid":    {Subject: "user", Issuer: "master", NotBefore: now.Add(time.Minute).Unix()},
+		"wrong issuer": {Subject: "user", Issuer: "other"},
+		"no subject":   {Issuer: "master"},
+	}
+	for name, claims := range invalid {
+		token, err := SignHMAC([]byte("secret"), claims)
+		assert.Nil(t, err)
+		_, err = authenticator.Verify(token)
+		assert.True(t, errors.Is(err, ErrUnauthorized), name)
+	}
+
+	token, err = SignHMAC([]byte("other secret"), Claims{Subject: "user", Issuer: "master"})
+	assert.Nil(t, err)
+	_, err = authenticator.Verify(token)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+
+	_, err = authenticator.Verify("not a token")
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	authenticator, err := NewRSA(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), "")
+	assert.Nil(t, err)
+
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	signed := encode(`{"alg":"RS256","typ":"JWT"}`) + "." + encode(`{"sub":"user","name":"tank"}`)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.Nil(t, err)
+
+	player := model.NewPlayer(1, 0, 0, nil)
+	assert.Nil(t, authenticator.Authorize(signed+"."+base64.RawURLEncoding.EncodeToString(signature), player))
+	assert.Equal(t, "user", player.GlobalID)
+
+	// tokens can not choose a weaker algorithm
+	token, err := SignHMAC(der, Claims{Subject: "user"})
+	assert.Nil(t, err)
+	_, err = authenticator.Verify(token)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
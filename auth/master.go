@@ -0,0 +1,25 @@
+package auth
+
+import "github.com/awdng/triebwerk/model"
+
+// PlayerAuthorizer is implemented by the master server client
+type PlayerAuthorizer interface {
+	AuthorizePlayer(token string, player *model.Player) error
+}
+
+// MasterServer lets the master server verify tokens
+type MasterServer struct {
+	master PlayerAuthorizer
+}
+
+// NewMasterServer ...
+func NewMasterServer(master PlayerAuthorizer) *MasterServer {
+	return &MasterServer{
+		master: master,
+	}
+}
+
+// Authorize Player with the master server
+func (m *MasterServer) Authorize(token string, player *model.Player) error {
+	return m.master.AuthorizePlayer(token, player)
+}
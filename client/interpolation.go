@@ -0,0 +1,144 @@
+// Package client contains the game logic of clients that is independent of the browser
+package client
+
+// snapshotBufferSize is the number of server snapshots kept per entity,
+// at a tickrate of 30 this covers roughly one second
+const snapshotBufferSize = 32
+
+// Snapshot of a networked entity at a given server game time
+type Snapshot struct {
+	GameTime       uint32
+	X              float32
+	Y              float32
+	Rotation       float32
+	TurretRotation float32
+}
+
+// snapshotBuffer is a ring buffer of snapshots ordered by game time
+type snapshotBuffer struct {
+	snapshots [snapshotBufferSize]Snapshot
+	start     int
+	count     int
+}
+
+// push a snapshot, snapshots older than the latest one are dropped
+func (b *snapshotBuffer) push(s Snapshot) {
+	if b.count > 0 {
+		latest := b.at(b.count - 1)
+		if s.GameTime < latest.GameTime {
+			return
+		}
+		if s.GameTime == latest.GameTime {
+			b.snapshots[(b.start+b.count-1)%snapshotBufferSize] = s
+			return
+		}
+	}
+
+	if b.count == snapshotBufferSize {
+		b.start = (b.start + 1) % snapshotBufferSize
+		b.count--
+	}
+	b.snapshots[(b.start+b.count)%snapshotBufferSize] = s
+	b.count++
+}
+
+func (b *snapshotBuffer) at(i int) Snapshot {
+	return b.snapshots[(b.start+i)%snapshotBufferSize]
+}
+
+// Interpolator buffers timestamped entity snapshots and renders them with a delay
+type Interpolator struct {
+	// Delay in milliseconds the rendered state lags behind the server
+	Delay float64
+	// MaxExtrapolation in milliseconds an entity is projected past its latest snapshot
+	MaxExtrapolation float64
+
+	buffers map[int]*snapshotBuffer
+}
+
+// NewInterpolator creates an Interpolator
+func NewInterpolator(delay float64, maxExtrapolation float64) *Interpolator {
+	return &Interpolator{
+		Delay:            delay,
+		MaxExtrapolation: maxExtrapolation,
+		buffers:          make(map[int]*snapshotBuffer),
+	}
+}
+
+// Add a snapshot of entity id received with the given server game time
+func (i *Interpolator) Add(id int, gameTime uint32, x, y, rotation, turretRotation float32) {
+	buffer, ok := i.buffers[id]
+	if !ok {
+		buffer = &snapshotBuffer{}
+		i.buffers[id] = buffer
+	}
+	buffer.push(Snapshot{
+		GameTime:       gameTime,
+		X:              x,
+		Y:              y,
+		Rotation:       rotation,
+		TurretRotation: turretRotation,
+	})
+}
+
+// Remove all snapshots of entity id
+func (i *Interpolator) Remove(id int) {
+	delete(i.buffers, id)
+}
+
+// Reset drops all buffered snapshots, eg. when a new game starts and the game time restarts
+func (i *Interpolator) Reset() {
+	i.buffers = make(map[int]*snapshotBuffer)
+}
+
+// State of entity id at renderTime (server game time in milliseconds) minus the configured delay
+func (i *Interpolator) State(id int, renderTime float64) (Snapshot, bool) {
+	buffer, ok := i.buffers[id]
+	if !ok || buffer.count == 0 {
+		return Snapshot{}, false
+	}
+
+	target := renderTime - i.Delay
+	first := buffer.at(0)
+	if buffer.count == 1 || target <= float64(first.GameTime) {
+		return first, true
+	}
+
+	for n := 1; n < buffer.count; n++ {
+		to := buffer.at(n)
+		if target > float64(to.GameTime) {
+			continue
+		}
+		from := buffer.at(n - 1)
+		return lerpSnapshot(from, to, target), true
+	}
+
+	// target is past the latest snapshot, extrapolate along the last known movement
+	from := buffer.at(buffer.count - 2)
+	to := buffer.at(buffer.count - 1)
+	if target-float64(to.GameTime) > i.MaxExtrapolation {
+		target = float64(to.GameTime) + i.MaxExtrapolation
+	}
+	return lerpSnapshot(from, to, target), true
+}
+
+// lerpSnapshot interpolates between two snapshots, t outside of [from, to] extrapolates
+func lerpSnapshot(from Snapshot, to Snapshot, target float64) Snapshot {
+	span := float64(to.GameTime) - float64(from.GameTime)
+	if span <= 0 {
+		return to
+	}
+	t := float32((target - float64(from.GameTime)) / span)
+
+	return Snapshot{
+		GameTime:       uint32(target),
+		X:              lerp(from.X, to.X, t),
+		Y:              lerp(from.Y, to.Y, t),
+		Rotation:       lerp(from.Rotation, to.Rotation, t),
+		TurretRotation: lerp(from.TurretRotation, to.TurretRotation, t),
+	}
+}
+
+func lerp(a float32, b float32, t float32) float32 {
+	return a + (b-a)*t
+}
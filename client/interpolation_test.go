@@ -0,0 +1,69 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolatorBuffersSnapshots(t *testing.T) {
+	interpolator := NewInterpolator(100, 50)
+	_, ok := interpolator.State(1, 1000)
+	assert.False(t, ok)
+
+	interpolator.Add(1, 1000, 0, 0, 0, 0)
+	interpolator.Add(1, 1100, 10, 20, 1, 2)
+
+	// entities are rendered between the snapshots around the delayed render time
+	state, ok := interpolator.State(1, 1150)
+	assert.True(t, ok)
+	assert.Equal(t, Snapshot{GameTime: 1050, X: 5, Y: 10, Rotation: 0.5, TurretRotation: 1}, state)
+
+	// before the first snapshot the entity stays at it
+	state, _ = interpolator.State(1, 1000)
+	assert.Equal(t, uint32(1000), state.GameTime)
+	assert.Equal(t, float32(0), state.X)
+
+	interpolator.Remove(1)
+	_, ok = interpolator.State(1, 1150)
+	assert.False(t, ok)
+}
+
+func TestInterpolatorClampsAtNewestSnapshot(t *testing.T) {
+	interpolator := NewInterpolator(0, 50)
+
+	// a single snapshot can not be extrapolated
+	interpolator.Add(1, 1000, 10, 10, 0, 0)
+	state, _ := interpolator.State(1, 2000)
+	assert.Equal(t, Snapshot{GameTime: 1000, X: 10, Y: 10}, state)
+
+	// past the newest snapshot the last movement is continued up to MaxExtrapolation
+	interpolator.Add(1, 1100, 20, 10, 0, 0)
+	state, _ = interpolator.State(1, 1125)
+	assert.Equal(t, float32(22.5), state.X)
+	state, _ = interpolator.State(1, 2000)
+	assert.Equal(t, Snapshot{GameTime: 1150, X: 25, Y: 10}, state)
+}
+
+func TestInterpolatorDropsOldSnapshots(t *testing.T) {
+	interpolator := NewInterpolator(0, 0)
+	interpolator.Add(1, 1000, 10, 0, 0, 0)
+
+	// snapshots older than the newest one arrived out of order and are dropped,
+	// a snapshot with the same game time replaces it
+	interpolator.Add(1, 900, 99, 0, 0, 0)
+	interpolator.Add(1, 1000, 20, 0, 0, 0)
+	state, _ := interpolator.State(1, 0)
+	assert.Equal(t, Snapshot{GameTime: 1000, X: 20}, state)
+
+	// a full buffer drops its oldest snapshot
+	for n := 1; n <= snapshotBufferSize; n++ {
+		interpolator.Add(1, uint32(1000+n*10), float32(n), 0, 0, 0)
+	}
+	state, _ = interpolator.State(1, 0)
+	assert.Equal(t, Snapshot{GameTime: 1010, X: 1}, state)
+
+	interpolator.Reset()
+	_, ok := interpolator.State(1, 0)
+	assert.False(t, ok)
+}
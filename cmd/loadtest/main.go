@@ -0,0 +1,316 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/awdng/triebwerk/auth"
+	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// message types sent by the server the load test reacts to
+const (
+	registerMessage   = 2
+	timeMessage       = 5
+	disconnectMessage = 9
+)
+
+// stats of all clients, counters are updated atomically
+type stats struct {
+	connected   int64
+	failed      int64
+	registered  int64
+	disconnects int64
+	messagesIn  int64
+	messagesOut int64
+	bytesIn     int64
+	bytesOut    int64
+
+	latencies []time.Duration
+	total     []time.Duration
+	mutex     sync.Mutex
+}
+
+func (s *stats) addLatency(latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.latencies = append(s.latencies, latency)
+	s.total = append(s.total, latency)
+}
+
+// takeLatencies returns the latencies measured since the last call
+func (s *stats) takeLatencies() []time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	latencies := s.latencies
+	s.latencies = nil
+	return latencies
+}
+
+type loadTest struct {
+	url     string
+	token   string
+	secret  string
+	clients int64
+	rate    int
+	started time.Time
+	stats   *stats
+	stop    chan struct{}
+}
+
+func main() {
+	url := flag.String("url", "ws://localhost:80/echo", "WebSocket URL of the game server")
+	clients := flag.Int("clients", 50, "number of clients")
+	ramp := flag.Duration("ramp", 20*time.Millisecond, "delay between opening two connections")
+	duration := flag.Duration("duration", time.Minute, "duration of the load test")
+	rate := flag.Int("rate", 30, "inputs per second sent by each client")
+	token := flag.String("token", "", "auth token the clients authenticate with, the nickname for servers with the anonymous provider")
+	secret := flag.String("jwt-secret", "", "sign a token for each client with this secret for servers with the jwt provider")
+	report := flag.Duration("report", 5*time.Second, "interval of intermediate reports")
+	flag.Parse()
+
+	test := &loadTest{
+		url:     *url,
+		token:   *token,
+		secret:  *secret,
+		rate:    *rate,
+		started: time.Now(),
+		stats:   &stats{},
+		stop:    make(chan struct{}),
+	}
+
+	log.Printf("LoadTest: Opening %d connections to %s", *clients, *url)
+	wg := &sync.WaitGroup{}
+	go func() {
+		for i := 0; i < *clients; i++ {
+			select {
+			case <-test.stop:
+				return
+			default:
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				test.runClient()
+			}()
+			time.Sleep(*ramp)
+		}
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	end := time.After(*duration)
+	ticker := time.NewTicker(*report)
+	last := time.Now()
+	var lastIn, lastOut int64
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			in, out := atomic.LoadInt64(&test.stats.bytesIn), atomic.LoadInt64(&test.stats.bytesOut)
+			elapsed := time.Since(last).Seconds()
+			log.Printf("LoadTest: %d connected, %d failed, %d disconnected by server, in %s/s, out %s/s, latency %s",
+				atomic.LoadInt64(&test.stats.connected), atomic.LoadInt64(&test.stats.failed), atomic.LoadInt64(&test.stats.disconnects),
+				formatBytes(float64(in-lastIn)/elapsed), formatBytes(float64(out-lastOut)/elapsed), summarize(test.stats.takeLatencies()))
+			last, lastIn, lastOut = time.Now(), in, out
+		case <-end:
+			break loop
+		case s := <-sigs:
+			log.Printf("LoadTest: Stopping with signal %s", s)
+			break loop
+		}
+	}
+	ticker.Stop()
+	close(test.stop)
+	wg.Wait()
+
+	s := test.stats
+	elapsed := time.Since(test.started).Seconds()
+	fmt.Printf("\nConnections:  %d succeeded, %d failed, %d registered, %d disconnected by server\n", s.connected, s.failed, s.registered, s.disconnects)
+	fmt.Printf("Messages:     %d received, %d sent\n", s.messagesIn, s.messagesOut)
+	fmt.Printf("Bandwidth:    in %s (%s/s), out %s (%s/s)\n",
+		formatBytes(float64(s.bytesIn)), formatBytes(float64(s.bytesIn)/elapsed), formatBytes(float64(s.bytesOut)), formatBytes(float64(s.bytesOut)/elapsed))
+	fmt.Printf("Latency:      %s\n", summarize(s.total))
+}
+
+// runClient connects, authenticates and sends inputs until the load test stops
+func (t *loadTest) runClient() {
+	conn, _, err := websocket.DefaultDialer.Dial(t.url, nil)
+	if err != nil {
+		atomic.AddInt64(&t.stats.failed, 1)
+		log.Printf("LoadTest: Could not connect: %s", err)
+		return
+	}
+	atomic.AddInt64(&t.stats.connected, 1)
+	defer conn.Close()
+
+	registered := make(chan int, 1)
+	closed := make(chan struct{})
+	go t.read(conn, registered, closed)
+
+	// the server registers the client once it is authenticated
+	token, err := t.clientToken()
+	if err != nil {
+		log.Printf("LoadTest: Could not create token: %s", err)
+		return
+	}
+	if !t.write(conn, protocol.EncodePlayerAuth(0, token)) {
+		return
+	}
+	var id int
+	select {
+	case id = <-registered:
+	case <-closed:
+		return
+	case <-t.stop:
+		return
+	}
+
+	inputs := time.NewTicker(time.Second / time.Duration(t.rate))
+	defer inputs.Stop()
+	timeRequests := time.NewTicker(time.Second)
+	defer timeRequests.Stop()
+	controls := model.Controls{}
+	for {
+		select {
+		case <-inputs.C:
+			controls = randomControls(controls)
+			if !t.write(conn, protocol.EncodePlayerInput(id, controls)) {
+				return
+			}
+		case <-timeRequests.C:
+			if !t.write(conn, protocol.EncodePlayerTime(id, t.clientTime())) {
+				return
+			}
+		case <-closed:
+			return
+		case <-t.stop:
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			return
+		}
+	}
+}
+
+// clientToken returns the token of a new client
+func (t *loadTest) clientToken() (string, error) {
+	n := atomic.AddInt64(&t.clients, 1)
+	if t.secret != "" {
+		return auth.SignHMAC([]byte(t.secret), auth.Claims{
+			Subject:   fmt.Sprintf("loadtest-%d", n),
+			Name:      fmt.Sprintf("LoadTest %d", n),
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		})
+	}
+	if t.token != "" {
+		return t.token, nil
+	}
+	return fmt.Sprintf("LoadTest %d", n), nil
+}
+
+// read all messages of the server, measures the latency with the echoed time of time messages
+func (t *loadTest) read(conn *websocket.Conn, registered chan<- int, closed chan<- struct{}) {
+	defer close(closed)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-t.stop:
+			default:
+				atomic.AddInt64(&t.stats.disconnects, 1)
+				log.Printf("LoadTest: Disconnected by server: %s", err)
+			}
+			return
+		}
+		atomic.AddInt64(&t.stats.messagesIn, 1)
+		atomic.AddInt64(&t.stats.bytesIn, int64(len(data)))
+
+		id, messageType, _, ok := protocol.DecodeHeader(data)
+		if !ok {
+			continue
+		}
+		switch messageType {
+		case registerMessage:
+			atomic.AddInt64(&t.stats.registered, 1)
+			select {
+			case registered <- id:
+			default:
+			}
+		case disconnectMessage:
+			if reason, ok := protocol.DecodeDisconnectReason(data); ok {
+				log.Printf("LoadTest: Rejected by server: %s", reason)
+			}
+		case timeMessage:
+			if sent, ok := protocol.DecodeServerTime(data); ok {
+				t.stats.addLatency(time.Duration(t.clientTime()-sent) * time.Millisecond)
+			}
+		}
+	}
+}
+
+func (t *loadTest) write(conn *websocket.Conn, data []byte) bool {
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		return false
+	}
+	atomic.AddInt64(&t.stats.messagesOut, 1)
+	atomic.AddInt64(&t.stats.bytesOut, int64(len(data)))
+	return true
+}
+
+// clientTime in milliseconds since the start of the load test
+func (t *loadTest) clientTime() uint32 {
+	return uint32(time.Since(t.started) / time.Millisecond)
+}
+
+// randomControls changes the controls now and then, like a player would
+func randomControls(controls model.Controls) model.Controls {
+	sequence := controls.Sequence + 1
+	if rand.Intn(15) == 0 {
+		controls = model.Controls{
+			Forward:     rand.Intn(3) != 0,
+			Left:        rand.Intn(4) == 0,
+			Right:       rand.Intn(4) == 0,
+			TurretLeft:  rand.Intn(4) == 0,
+			TurretRight: rand.Intn(4) == 0,
+		}
+	}
+	controls.Shoot = rand.Intn(10) == 0
+	controls.Sequence = sequence
+	return controls
+}
+
+// summarize latencies as min, percentiles and max
+func summarize(latencies []time.Duration) string {
+	if len(latencies) == 0 {
+		return "n/a"
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	percentile := func(p float64) time.Duration {
+		return latencies[int(float64(len(latencies)-1)*p)]
+	}
+	return fmt.Sprintf("min %s, p50 %s, p95 %s, p99 %s, max %s (%d samples)",
+		latencies[0], percentile(0.5), percentile(0.95), percentile(0.99), latencies[len(latencies)-1], len(latencies))
+}
+
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	pb "github.com/awdng/triebwerk-proto/gameserver"
+	"github.com/awdng/triebwerk/infra/mastersim"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	port := flag.Int("port", 8081, "port of the gRPC service, the game server connects to it with MASTERSERVER_GRPC")
+	tokens := flag.String("tokens", "", "accepted auth tokens as token:name[:globalID],..., every token is accepted if empty")
+	expiry := flag.Duration("expiry", 30*time.Second, "servers missing heartbeats for this long are dropped")
+	flag.Parse()
+
+	accounts, err := mastersim.ParseTokens(*tokens)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterGameServerMasterServer(server, mastersim.NewServer(accounts, *expiry))
+
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		s := <-sigs
+		log.Printf("MasterSim: Stopping with signal %s", s)
+		server.GracefulStop()
+	}()
+
+	if len(accounts) == 0 {
+		log.Printf("MasterSim: Every token is authorized")
+	}
+	log.Printf("MasterSim: Listening on %s", listener.Addr())
+	if err := server.Serve(listener); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/awdng/triebwerk/game"
+	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/protocol"
+	"github.com/awdng/triebwerk/replay"
+	websocket "github.com/awdng/triebwerk/transport"
+)
+
+// viewers get IDs from the top of the ID range so they never collide with recorded Players
+const firstViewerID = 255
+
+func main() {
+	file := flag.String("file", "", "replay file to play")
+	address := flag.String("address", "127.0.0.1", "address to serve the replay on")
+	port := flag.Int("serve", 0, "serve the replay to spectator clients on this port instead of verifying it")
+	speed := flag.Float64("speed", 1, "playback speed when serving")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	reader, err := replay.NewReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	simulator, err := replay.NewSimulator(reader.Header)
+	if err != nil {
+		log.Fatal(err)
+	}
+	header := reader.Header
+	log.Printf("Replay: %s on map %s in %s, started %s, %d ticks/s", *file, header.MapID, header.Region, header.StartedAt.Format(time.RFC3339), header.Tickrate)
+
+	if *port == 0 {
+		verify(reader, simulator)
+		return
+	}
+	serve(reader, simulator, *address, *port, *speed)
+}
+
+// verify re-simulates the replay as fast as possible and reports where it diverges from the recorded keyframes
+func verify(reader *replay.Reader, simulator *replay.Simulator) {
+	events := 0
+	for {
+		e, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := simulator.Apply(e); err != nil {
+			log.Fatal(err)
+		}
+		events++
+	}
+
+	for _, m := range simulator.Mismatches {
+		log.Printf("Replay: Mismatch at %s", m)
+	}
+	if !simulator.Ended {
+		log.Printf("Replay: Replay is truncated, the match did not end")
+	}
+	log.Printf("Replay: Simulated %d events, %d mismatches", events, len(simulator.Mismatches))
+	if len(simulator.Mismatches) > 0 {
+		os.Exit(1)
+	}
+}
+
+// serve plays the replay in real time to spectator clients
+func serve(reader *replay.Reader, simulator *replay.Simulator, address string, port int, speed float64) {
+	state := simulator.State
+	transport := websocket.NewTransport(address, port)
+	networkManager := game.NewNetworkManager(transport, protocol.NewBinaryProtocol())
+
+	var mutex sync.Mutex
+	nextID := firstViewerID
+	transport.RegisterNewConnHandler(func(conn model.Connection) {
+		mutex.Lock()
+		spectator := model.NewSpectator(nextID, 0, networkManager.NewClient(conn))
+		nextID--
+		mutex.Unlock()
+		networkManager.Accept(spectator.Client)
+		networkManager.RegisterSpectator(spectator, state)
+		state.AddSpectator(spectator)
+		log.Printf("Replay: Spectator %d connected", spectator.ID)
+	})
+	transport.UnregisterConnHandler(func(conn model.Connection) {
+		for _, s := range state.GetSpectators() {
+			if s.Client.Connection == conn {
+				state.RemoveSpectator(s)
+				log.Printf("Replay: Spectator %d disconnected", s.ID)
+			}
+		}
+	})
+
+	go func() {
+		log.Fatal(networkManager.Start())
+	}()
+
+	state.Start()
+	networkManager.BroadcastGameStart(state)
+	log.Printf("Replay: Serving on %s", networkManager.GetAddress())
+
+	interval := time.Duration(float64(time.Second) / float64(simulator.Header.Tickrate) / speed)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// events are read one ahead, the pending event belongs to a later tick
+	e, err := reader.Next()
+	for tick := uint32(0); err == nil && !simulator.Ended; tick++ {
+		for err == nil && e.Tick <= tick {
+			if err := simulator.Apply(e); err != nil {
+				log.Fatal(err)
+			}
+			e, err = reader.Next()
+		}
+		for _, s := range state.GetSpectators() {
+			processSpectatorInputs(networkManager, state, s)
+		}
+		networkManager.BroadcastGameState(state)
+		<-ticker.C
+	}
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+
+	state.End()
+	networkManager.BroadcastGameEnd(state)
+	networkManager.BroadcastResults(state.Results(0), state)
+	log.Printf("Replay: Playback has ended")
+	time.Sleep(10 * time.Second)
+}
+
+func processSpectatorInputs(networkManager *game.NetworkManager, state *model.GameState, s *model.Spectator) {
+	// at most one scoreboard per tick, however often it was requested
+	scoreboardRequested := false
+	for len(s.Client.NetworkIn) != 0 {
+		message := <-s.Client.NetworkIn
+		switch message.MessageType {
+		case 5:
+			networkManager.SendTime(s.ID, s.Client, state, &message)
+		case 8:
+			s.Follow = message.Body.(int)
+			networkManager.SendFollow(s, state)
+		case 11:
+			scoreboardRequested = true
+		}
+	}
+	if scoreboardRequested {
+		networkManager.SendScoreboard(s.ID, s.Client, state)
+	}
+}
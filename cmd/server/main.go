@@ -2,18 +2,23 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
 	firebase "firebase.google.com/go"
 	"github.com/awdng/triebwerk"
+	"github.com/awdng/triebwerk/auth"
 	"github.com/awdng/triebwerk/game"
 	"github.com/awdng/triebwerk/infra"
 	"github.com/awdng/triebwerk/protocol"
+	"github.com/awdng/triebwerk/storage"
 	websocket "github.com/awdng/triebwerk/transport"
+	"github.com/awdng/triebwerk/transport/udp"
 	"github.com/kelseyhightower/envconfig"
 
 	pb "github.com/awdng/triebwerk-proto/gameserver"
@@ -27,51 +32,63 @@ func main() {
 		log.Fatal(err)
 	}
 
-	ctx := context.Background()
-	firebaseConfig := &firebase.Config{}
-
-	app, err := firebase.NewApp(ctx, firebaseConfig)
-	if err != nil {
-		log.Fatal(err)
+	// Firebase is only needed by the firebase auth provider and the firestore storage backend
+	var app *triebwerk.Firebase
+	if strings.EqualFold(config.AuthProvider, auth.ProviderFirebase) || strings.EqualFold(config.Storage, storage.BackendFirestore) {
+		var err error
+		if app, err = newFirebase(context.Background()); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	client, err := app.Firestore(ctx)
+	store, err := storage.New(config, app)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer store.Close()
 
-	firebase := &triebwerk.Firebase{
-		App:   app,
-		Store: client,
-	}
-
-	var opts []grpc.DialOption
-	opts = append(opts, grpc.WithBlock())
-	opts = append(opts, grpc.WithInsecure())
-	opts = append(opts, grpc.WithTimeout(5*time.Second))
-
-	var conn *grpc.ClientConn
-	for conn == nil {
-		conn, err = grpc.Dial(config.MasterServerGRPC, opts...)
-		if err != nil {
-			log.Printf("failed to connect to GRPC backend: %v", err)
-			log.Println("Retrying...")
-			time.Sleep(2 * time.Second)
-		}
+	// the connection is established in the background, the game runs offline until the master server is reachable
+	conn, err := grpc.Dial(config.MasterServerGRPC, grpc.WithInsecure())
+	if err != nil {
+		log.Fatalf("invalid master server address %s: %v", config.MasterServerGRPC, err)
 	}
-
 	defer conn.Close()
 	pbclient := pb.NewGameServerMasterClient(conn)
 	masterServer := infra.NewMasterServerClient(pbclient)
 
 	log.Printf("Loading Triebwerk ...")
 
-	playerManager := game.NewPlayerManager(firebase)
+	authenticator, err := auth.New(config, app, masterServer)
+	if err != nil {
+		log.Fatal(err)
+	}
 	transport := websocket.NewTransport(config.PublicIP, config.Port)
 	networkManager := game.NewNetworkManager(transport, protocol.NewBinaryProtocol())
-	controller := game.NewController(config.Region, networkManager, playerManager, firebase, masterServer)
+	networkManager.SetQueueSizes(config.ReliableQueueSize, config.UnreliableQueueSize)
+	controller := game.NewController(config, networkManager, authenticator, store, masterServer)
 	transport.RegisterNewConnHandler(controller.RegisterPlayer)
 	transport.UnregisterConnHandler(controller.UnregisterPlayer)
+	transport.HandleFunc("/leaderboard", storage.LeaderboardHandler(store))
+
+	if config.AdminAddress != "" {
+		// metrics of the infra worker and runtime, on their own listener apart from the public game port
+		admin := http.NewServeMux()
+		admin.Handle("/debug/vars", expvar.Handler())
+		go func() {
+			log.Fatal(http.ListenAndServe(config.AdminAddress, admin))
+		}()
+	}
+
+	if config.UDPPort != 0 {
+		udpTransport := udp.NewTransport(config.PublicIP, config.UDPPort, config.UDPMTU)
+		udpTransport.RegisterNewConnHandler(controller.RegisterPlayer)
+		udpTransport.UnregisterConnHandler(controller.UnregisterPlayer)
+		networkManager.AddTransport(udpTransport)
+	}
+
+	if config.CasterPort != 0 {
+		networkManager.EnableDelayedBroadcast(websocket.NewTransport(config.PublicIP, config.CasterPort), config.CasterDelay)
+	}
 
 	go func() {
 		// start game server
@@ -82,4 +99,23 @@ func main() {
 
 	s := <-sigs
 	log.Printf("shutdown with signal %s", s)
+	// queued calls of the last game still reach the master server and the store
+	controller.Close()
+	masterServer.Stop()
+}
+
+// newFirebase initializes the Firebase app with the credentials of GOOGLE_APPLICATION_CREDENTIALS
+func newFirebase(ctx context.Context) (*triebwerk.Firebase, error) {
+	app, err := firebase.NewApp(ctx, &firebase.Config{})
+	if err != nil {
+		return nil, err
+	}
+	client, err := app.Firestore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &triebwerk.Firebase{
+		App:   app,
+		Store: client,
+	}, nil
 }
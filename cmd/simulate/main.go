@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/awdng/triebwerk"
+	"github.com/awdng/triebwerk/auth"
+	"github.com/awdng/triebwerk/game"
+	"github.com/awdng/triebwerk/infra"
+	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/nav"
+	"github.com/awdng/triebwerk/protocol"
+	"github.com/awdng/triebwerk/storage"
+	"github.com/awdng/triebwerk/transport/memory"
+)
+
+// offlineMasterServer reports nothing
+type offlineMasterServer struct{}
+
+func (offlineMasterServer) Init(address string) error                 { return nil }
+func (offlineMasterServer) GetServerState()                           {}
+func (offlineMasterServer) SendHeartbeat(*infra.ServerSnapshot) error { return nil }
+func (offlineMasterServer) EndGame(*infra.ServerSnapshot) error       { return nil }
+
+// playerStats collected during the simulation
+type playerStats struct {
+	deaths int
+	alive  bool
+}
+
+func main() {
+	mapID := flag.String("map", "default", "map to play on")
+	numPlayers := flag.Int("players", 8, "number of virtual players")
+	inputs := flag.String("inputs", "random", "input mode of the virtual players: random, scripted or bots")
+	difficulty := flag.String("difficulty", "normal", "difficulty of the virtual players in bots mode")
+	seed := flag.Int64("seed", 1, "seed of the simulation, the same seed produces the same match")
+	maxTicks := flag.Int("ticks", 0, "stop after this many ticks instead of the full game length")
+	replayDir := flag.String("replay", "", "save a replay of the match to this directory")
+	verbose := flag.Bool("v", false, "log game events")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(ioutil.Discard)
+	}
+
+	var control func(rng *rand.Rand, p *model.Player, tick int) model.Controls
+	switch *inputs {
+	case "random":
+		control = randomControls
+	case "scripted":
+		control = scriptedControls
+	case "bots":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown input mode %s\n", *inputs)
+		os.Exit(2)
+	}
+
+	transport := memory.NewTransport(memory.Options{})
+	defer transport.Close()
+	networkManager := game.NewNetworkManager(transport, protocol.NewBinaryProtocol())
+	config := triebwerk.Config{Region: "simulation", ReplayDir: *replayDir}
+	controller := game.NewController(config, networkManager, auth.NewAnonymous(), storage.NewMemory(), offlineMasterServer{})
+	defer controller.Close()
+	state := controller.State()
+	if state.Map.ID != *mapID {
+		fmt.Fprintf(os.Stderr, "unknown map %s\n", *mapID)
+		os.Exit(2)
+	}
+	if *numPlayers < 1 || *numPlayers > len(state.Map.Spawns) {
+		fmt.Fprintf(os.Stderr, "players must be between 1 and %d, the number of spawns of map %s\n", len(state.Map.Spawns), state.Map.ID)
+		os.Exit(2)
+	}
+	controller.EnableManualStepping(model.NewManualClock(time.Unix(0, 0)), *seed)
+	transport.RegisterNewConnHandler(controller.RegisterPlayer)
+	transport.UnregisterConnHandler(controller.UnregisterPlayer)
+	go networkManager.Start()
+
+	for i := 0; i < *numPlayers; i++ {
+		conn := transport.Dial(nil)
+		// discard everything sent to the virtual player
+		go func() {
+			for {
+				if _, err := conn.Read(); err != nil {
+					return
+				}
+			}
+		}()
+		// players join one after another once authenticated, so the spawns only depend on the seed
+		conn.Write(protocol.EncodePlayerAuth(0, fmt.Sprintf("Player %d", i+1)))
+		for state.GetPlayerCount() < i+1 {
+			time.Sleep(time.Millisecond)
+			controller.ApplyEvents()
+		}
+	}
+	for !state.InProgress() {
+		time.Sleep(time.Millisecond)
+	}
+
+	players := state.GetPlayers()
+	stats := make(map[int]*playerStats)
+	spawns := make(map[model.Point]int)
+	for _, p := range players {
+		stats[p.ID] = &playerStats{alive: true}
+		spawns[*p.Collider.Pivot]++
+	}
+
+	if *inputs == "bots" {
+		botDifficulty, err := game.ParseDifficulty(*difficulty)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		grid := nav.NewGrid(state.Map, nav.DefaultCellSize)
+		bots := make(map[int]*game.Bot)
+		for _, p := range players {
+			bots[p.ID] = game.NewBot(p, botDifficulty, grid)
+		}
+		control = func(rng *rand.Rand, p *model.Player, tick int) model.Controls {
+			return bots[p.ID].Think(state, players)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(*seed))
+	tickTimes := []time.Duration{}
+	started := time.Now()
+	for tick := 0; *maxTicks == 0 || tick < *maxTicks; tick++ {
+		for _, p := range players {
+			p.Client.NetworkIn <- model.NetworkMessage{MessageType: 1, Body: control(rng, p, tick)}
+		}
+
+		tickStart := time.Now()
+		running := controller.Step()
+		tickTimes = append(tickTimes, time.Since(tickStart))
+
+		for _, p := range players {
+			s := stats[p.ID]
+			if s.alive && !p.IsAlive() {
+				s.deaths++
+			}
+			if !s.alive && p.IsAlive() {
+				spawns[*p.Collider.Pivot]++
+			}
+			s.alive = p.IsAlive()
+		}
+		if !running {
+			break
+		}
+	}
+	elapsed := time.Since(started)
+
+	fmt.Printf("Simulated %d ticks (%s of game time) with %d players on map %s in %s\n\n",
+		len(tickTimes), time.Duration(state.GameTime())*time.Millisecond, len(players), state.Map.ID, elapsed)
+
+	fmt.Printf("%-8s %-8s %-8s\n", "Player", "Kills", "Deaths")
+	sort.SliceStable(players, func(i, j int) bool {
+		return players[i].Score > players[j].Score
+	})
+	for _, p := range players {
+		fmt.Printf("%-8d %-8d %-8d\n", p.ID, p.Score, stats[p.ID].deaths)
+	}
+
+	sort.Slice(tickTimes, func(i, j int) bool {
+		return tickTimes[i] < tickTimes[j]
+	})
+	var total time.Duration
+	for _, d := range tickTimes {
+		total += d
+	}
+	if len(tickTimes) > 0 {
+		fmt.Printf("\nTick time: min %s, avg %s, p50 %s, p99 %s, max %s\n",
+			tickTimes[0], total/time.Duration(len(tickTimes)), percentile(tickTimes, 0.5), percentile(tickTimes, 0.99), tickTimes[len(tickTimes)-1])
+	}
+
+	fmt.Printf("\n%-24s %-8s\n", "Spawn", "Count")
+	for _, spawn := range state.Map.Spawns {
+		fmt.Printf("%-24s %-8d\n", fmt.Sprintf("(%.1f, %.1f)", spawn.X, spawn.Y), spawns[*spawn])
+	}
+}
+
+// percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+// randomControls changes the input of a Player every half second
+func randomControls(rng *rand.Rand, p *model.Player, tick int) model.Controls {
+	if tick%15 != 0 {
+		return p.Control
+	}
+	return model.Controls{
+		Forward:     rng.Intn(3) != 0,
+		Backward:    rng.Intn(6) == 0,
+		Left:        rng.Intn(3) == 0,
+		Right:       rng.Intn(3) == 0,
+		TurretLeft:  rng.Intn(3) == 0,
+		TurretRight: rng.Intn(3) == 0,
+		Shoot:       rng.Intn(2) == 0,
+	}
+}
+
+// scriptedControls drives in circles of a size depending on the Player while sweeping the turret and shooting
+func scriptedControls(rng *rand.Rand, p *model.Player, tick int) model.Controls {
+	phase := (tick / (30 + 10*p.ID)) % 4
+	return model.Controls{
+		Forward:     phase != 3,
+		Backward:    phase == 3,
+		Left:        phase == 1,
+		Right:       phase == 2,
+		TurretLeft:  (tick/45)%2 == 0,
+		TurretRight: (tick/45)%2 == 1,
+		Shoot:       tick%10 == 0,
+	}
+}
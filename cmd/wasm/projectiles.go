@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"syscall/js"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+func findPlayer(id int) *model.Player {
+	for _, p := range players {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// applyWeaponInput runs the weapon logic of the server for the local player: projectiles are moved and
+// collided, the cooldown is advanced and a new projectile is fired if shoot is pressed and the weapon is ready.
+// Returns true if a projectile was fired.
+func applyWeaponInput(this js.Value, args []js.Value) interface{} {
+	if localPlayer == nil {
+		return js.ValueOf(false)
+	}
+	dt := float32(args[0].Float())
+	// the weapon may become ready and fire within the same step, so shots are counted instead
+	shots := localPlayer.Stats.ShotsFired
+
+	localPlayer.Control = controls
+	localPlayer.HandleWeapons(players, gameState.Map, dt)
+
+	return js.ValueOf(localPlayer.Stats.ShotsFired > shots)
+}
+
+// spawnProjectile fires the weapon of a network player from its current turret position,
+// the weapon cooldown applies. Returns true if a projectile was fired.
+func spawnProjectile(this js.Value, args []js.Value) interface{} {
+	player := findPlayer(args[0].Int())
+	if player == nil || player == localPlayer {
+		return js.ValueOf(false)
+	}
+
+	weapon := player.Weapons[0]
+	if !weapon.Ready() {
+		return js.ValueOf(false)
+	}
+	weapon.ShootAt(player.Collider.Turret.X, player.Collider.Turret.Y)
+	return js.ValueOf(true)
+}
+
+// updateProjectiles steps the weapons of all network players, the local player is stepped by applyWeaponInput
+func updateProjectiles(this js.Value, args []js.Value) interface{} {
+	dt := float32(args[0].Float())
+	for _, p := range players {
+		if p == localPlayer {
+			continue
+		}
+		for _, w := range p.Weapons {
+			w.Update(players, gameState.Map, dt)
+		}
+	}
+	return js.ValueOf(nil)
+}
+
+// getProjectiles returns position and direction of all projectiles of a player
+func getProjectiles(this js.Value, args []js.Value) interface{} {
+	player := findPlayer(args[0].Int())
+	if player == nil {
+		return js.ValueOf(nil)
+	}
+
+	var uint8Array = js.Global().Get("Uint8Array")
+	buf := make([]byte, 0)
+	for _, w := range player.Weapons {
+		for _, projectile := range w.Projectiles {
+			value := make([]byte, 4)
+			for _, f := range []float32{projectile.Position.X, projectile.Position.Y, projectile.Direction.X, projectile.Direction.Y} {
+				binary.LittleEndian.PutUint32(value[:], math.Float32bits(f))
+				buf = append(buf, value...)
+			}
+		}
+	}
+
+	dst := uint8Array.New(len(buf))
+	js.CopyBytesToJS(dst, buf)
+	return dst
+}
+
+// setPlayerHealth syncs the health of a player with the server, locally predicted hits
+// only apply to the local copy until the server state arrives
+func setPlayerHealth(this js.Value, args []js.Value) interface{} {
+	player := findPlayer(args[0].Int())
+	if player == nil {
+		return js.ValueOf(nil)
+	}
+	player.Health = args[1].Int()
+	return js.ValueOf(nil)
+}
+
+func registerProjectileCallbacks() {
+	js.Global().Set("applyWeaponInput", js.FuncOf(applyWeaponInput))
+	js.Global().Set("spawnProjectile", js.FuncOf(spawnProjectile))
+	js.Global().Set("updateProjectiles", js.FuncOf(updateProjectiles))
+	js.Global().Set("getProjectiles", js.FuncOf(getProjectiles))
+	js.Global().Set("setPlayerHealth", js.FuncOf(setPlayerHealth))
+}
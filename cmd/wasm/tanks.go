@@ -6,6 +6,7 @@ import (
 	"math"
 	"syscall/js"
 
+	"github.com/awdng/triebwerk/client"
 	"github.com/awdng/triebwerk/model"
 	"github.com/awdng/triebwerk/protocol"
 )
@@ -14,6 +15,7 @@ var players = make([]*model.Player, 0)
 var localPlayer *model.Player
 var gameState = model.NewGameState("local")
 var controls = model.Controls{}
+var interpolator = client.NewInterpolator(100, 250)
 
 func setInput(this js.Value, args []js.Value) interface{} {
 	controls.Forward = !(args[0].Int() == 0)
@@ -160,6 +162,8 @@ func createNetworkPlayer(this js.Value, args []js.Value) interface{} {
 	return js.ValueOf(nil)
 }
 
+// updateNetworkPlayer applies the latest server state of a player, if the game time of the
+// packet header is passed as 6th argument the state is buffered for interpolation instead
 func updateNetworkPlayer(this js.Value, args []js.Value) interface{} {
 	id := args[0].Int()
 	x := float32(args[1].Float())
@@ -167,20 +171,83 @@ func updateNetworkPlayer(this js.Value, args []js.Value) interface{} {
 	rotation := float32(args[3].Float())
 	turretRotation := float32(args[4].Float())
 
+	if len(args) > 5 {
+		interpolator.Add(id, uint32(args[5].Int()), x, y, rotation, turretRotation)
+		return js.ValueOf(nil)
+	}
+
+	for _, player := range players {
+		if player.ID != id {
+			continue
+		}
+		setNetworkPlayerState(player, x, y, rotation, turretRotation)
+	}
+
+	return js.ValueOf(nil)
+}
+
+func setNetworkPlayerState(player *model.Player, x, y, rotation, turretRotation float32) {
+	player.Collider.ChangePosition(x, y)
+	player.Collider.Rotation = rotation
+	player.Collider.TurretRotation = turretRotation
+}
+
+// getInterpolatedState returns the buffered state of a player at renderTime (estimated server game time),
+// the state is applied to the local player copy so collision checks match what is rendered
+func getInterpolatedState(this js.Value, args []js.Value) interface{} {
+	id := args[0].Int()
+	renderTime := args[1].Float()
+
+	state, ok := interpolator.State(id, renderTime)
+	if !ok {
+		return js.ValueOf(nil)
+	}
+
 	for _, player := range players {
 		if player.ID != id {
 			continue
 		}
-		player.Collider.ChangePosition(x, y)
-		player.Collider.Rotation = rotation
-		player.Collider.TurretRotation = turretRotation
+		setNetworkPlayerState(player, state.X, state.Y, state.Rotation, state.TurretRotation)
 	}
 
+	var uint8Array = js.Global().Get("Uint8Array")
+	buf := make([]byte, 0, 16)
+	posX := make([]byte, 4)
+	posY := make([]byte, 4)
+	rotation := make([]byte, 4)
+	turretRotation := make([]byte, 4)
+
+	binary.LittleEndian.PutUint32(posX[:], math.Float32bits(state.X))
+	binary.LittleEndian.PutUint32(posY[:], math.Float32bits(state.Y))
+	binary.LittleEndian.PutUint32(rotation[:], math.Float32bits(state.Rotation))
+	binary.LittleEndian.PutUint32(turretRotation[:], math.Float32bits(state.TurretRotation))
+
+	buf = append(buf, posX...)
+	buf = append(buf, posY...)
+	buf = append(buf, rotation...)
+	buf = append(buf, turretRotation...)
+
+	dst := uint8Array.New(len(buf))
+	js.CopyBytesToJS(dst, buf)
+	return dst
+}
+
+// setInterpolation configures the render delay and the maximum extrapolation in milliseconds
+func setInterpolation(this js.Value, args []js.Value) interface{} {
+	interpolator.Delay = args[0].Float()
+	interpolator.MaxExtrapolation = args[1].Float()
+	return js.ValueOf(nil)
+}
+
+// resetInterpolation drops all buffered snapshots, the game time restarts with every game
+func resetInterpolation(this js.Value, args []js.Value) interface{} {
+	interpolator.Reset()
 	return js.ValueOf(nil)
 }
 
 func removePlayer(this js.Value, args []js.Value) interface{} {
 	id := args[0].Int()
+	interpolator.Remove(id)
 	if localPlayer != nil && localPlayer.ID == id {
 		localPlayer = nil
 		return js.ValueOf(nil)
@@ -205,7 +272,11 @@ func registerCallbacks() {
 	js.Global().Set("removePlayer", js.FuncOf(removePlayer))
 	js.Global().Set("updateNetworkPlayer", js.FuncOf(updateNetworkPlayer))
 	js.Global().Set("getPlayerState", js.FuncOf(getPlayerState))
+	js.Global().Set("getInterpolatedState", js.FuncOf(getInterpolatedState))
+	js.Global().Set("setInterpolation", js.FuncOf(setInterpolation))
+	js.Global().Set("resetInterpolation", js.FuncOf(resetInterpolation))
 	js.Global().Set("checkProjectileCollision", js.FuncOf(checkProjectileCollision))
+	registerProjectileCallbacks()
 }
 
 var proto protocol.BinaryProtocol
@@ -0,0 +1,100 @@
+package game
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// reasons a connection is closed before joining the game
+const (
+	reasonUnauthorized = "unauthorized"
+	reasonAuthTimeout  = "authentication timed out"
+	reasonBusy         = "server busy"
+)
+
+// pendingPlayers are connected but not authorized yet, they are not part of the game
+type pendingPlayers struct {
+	players map[model.Connection]*model.Player
+	mutex   sync.Mutex
+}
+
+func newPendingPlayers() *pendingPlayers {
+	return &pendingPlayers{
+		players: make(map[model.Connection]*model.Player),
+	}
+}
+
+func (p *pendingPlayers) add(player *model.Player) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.players[player.Client.Connection] = player
+}
+
+// remove a pending Player, returns false if it was not pending anymore
+func (p *pendingPlayers) remove(conn model.Connection) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	_, ok := p.players[conn]
+	delete(p.players, conn)
+	return ok
+}
+
+// authenticate a pending Player, it joins the game once the token of its auth message is verified.
+// The token is verified by the worker, so the game never waits for an auth provider
+func (g *Controller) authenticate(player *model.Player) {
+	var timeout <-chan time.Time // never times out if disabled
+	if g.authTimeout > 0 {
+		timer := time.NewTimer(g.authTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		select {
+		case message, ok := <-player.Client.NetworkIn:
+			if !ok { // disconnected
+				return
+			}
+			if message.MessageType != 0 {
+				continue
+			}
+			token := message.Body.(string)
+			submitted := g.submit("authorize", func() error {
+				return g.authenticator.Authorize(token, player)
+			}, func(err error) {
+				g.authorized(player, err)
+			})
+			if !submitted {
+				g.reject(player, reasonBusy)
+			}
+			return
+		case <-timeout:
+			log.Printf("GameManager: Player %d did not authenticate within %s, closing connection", player.ID, g.authTimeout)
+			g.reject(player, reasonAuthTimeout)
+			return
+		}
+	}
+}
+
+// authorized is the result of authenticating a pending Player
+func (g *Controller) authorized(player *model.Player, err error) {
+	if err != nil {
+		log.Printf("GameManager: Player %d could not be authorized, closing connection: %s", player.ID, err)
+		g.reject(player, reasonUnauthorized)
+		return
+	}
+	if !g.pending.remove(player.Client.Connection) { // disconnected in the meantime
+		return
+	}
+	log.Printf("GameManager: Player %d authorized successfully as GlobalID %s %s", player.ID, player.GlobalID, player.Nickname)
+	g.joinPlayer(player)
+}
+
+func (g *Controller) reject(player *model.Player, reason string) {
+	if g.pending.remove(player.Client.Connection) {
+		g.networkManager.Reject(player.Client, reason)
+	}
+}
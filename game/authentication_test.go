@@ -0,0 +1,93 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awdng/triebwerk"
+	"github.com/awdng/triebwerk/auth"
+	"github.com/awdng/triebwerk/internal/testutil"
+	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/protocol"
+	"github.com/awdng/triebwerk/storage"
+	"github.com/awdng/triebwerk/transport/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// testAuthenticator accepts every token but "invalid"
+type testAuthenticator struct{}
+
+func (testAuthenticator) Authorize(token string, player *model.Player) error {
+	if token == "invalid" {
+		return auth.ErrUnauthorized
+	}
+	player.Nickname = token
+	return nil
+}
+
+func TestAuthentication(t *testing.T) {
+	config := triebwerk.Config{Region: "test", AuthTimeout: 50 * time.Millisecond}
+	controller, transport := newTestController(t, config, testAuthenticator{}, storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+
+	// connections are closed with the reason before they join the game
+	expectRejected := func(conn *memory.Connection, reason string) {
+		read := make(chan []byte, 1)
+		go func() {
+			data, _ := conn.Read()
+			read <- data
+		}()
+		var data []byte
+		await(t, controller, func() bool {
+			select {
+			case data = <-read:
+				return true
+			default:
+				return false
+			}
+		})
+		rejected, ok := protocol.DecodeDisconnectReason(data)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, reason, rejected)
+		_, err := conn.Read()
+		assert.Equal(t, memory.ErrClosed, err)
+		assert.Equal(t, 0, controller.State().GetPlayerCount())
+	}
+
+	invalid := transport.Dial(nil)
+	assert.Nil(t, invalid.Write(protocol.EncodePlayerInput(0, model.Controls{Forward: true})))
+	assert.Nil(t, invalid.Write(protocol.EncodePlayerAuth(0, "invalid")))
+	expectRejected(invalid, reasonUnauthorized)
+
+	silent := transport.Dial(nil)
+	expectRejected(silent, reasonAuthTimeout)
+
+	player := join(t, transport, controller)
+	assert.Equal(t, "tank", controller.State().GetPlayers()[0].Nickname)
+	data, err := player.Read()
+	assert.Nil(t, err)
+	_, messageType, _, _ := protocol.DecodeHeader(data)
+	assert.Equal(t, uint8(2), messageType)
+}
+
+func TestRejectAfterDisconnect(t *testing.T) {
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, testAuthenticator{}, storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+
+	// the connection closes after the token was rejected, before the rejection is applied
+	conn := transport.Dial(nil)
+	assert.Nil(t, conn.Write(protocol.EncodePlayerAuth(0, "invalid")))
+	testutil.Eventually(t, func() bool {
+		return len(controller.events) == 1
+	})
+	conn.Close(0, false)
+	testutil.Eventually(t, func() bool {
+		controller.disconnectedMutex.Lock()
+		defer controller.disconnectedMutex.Unlock()
+		return len(controller.disconnected) == 1
+	})
+
+	controller.ApplyEvents()
+	assert.Equal(t, 0, controller.State().GetPlayerCount())
+	assert.False(t, controller.pending.remove(conn))
+}
@@ -0,0 +1,348 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/nav"
+	"github.com/awdng/triebwerk/replay"
+)
+
+// Difficulty of the bots
+type Difficulty int
+
+const (
+	// BotEasy bots react slowly and miss a lot
+	BotEasy Difficulty = iota
+	// BotNormal ...
+	BotNormal
+	// BotHard bots react fast, see far and aim precisely
+	BotHard
+)
+
+// ParseDifficulty from its name
+func ParseDifficulty(name string) (Difficulty, error) {
+	switch strings.ToLower(name) {
+	case "easy":
+		return BotEasy, nil
+	case "normal", "":
+		return BotNormal, nil
+	case "hard":
+		return BotHard, nil
+	}
+	return BotNormal, fmt.Errorf("unknown bot difficulty %s", name)
+}
+
+func (d Difficulty) String() string {
+	switch d {
+	case BotEasy:
+		return "easy"
+	case BotHard:
+		return "hard"
+	}
+	return "normal"
+}
+
+type botSettings struct {
+	// reactionTicks between two decisions about the target
+	reactionTicks int
+	// viewDistance enemies are noticed within
+	viewDistance float32
+	// aimTolerance in radians the turret may be off when shooting
+	aimTolerance float64
+	// keepDistance the bot tries to keep to its target
+	keepDistance float32
+}
+
+var difficulties = map[Difficulty]botSettings{
+	BotEasy:   {reactionTicks: 20, viewDistance: 60, aimTolerance: 0.3, keepDistance: 20},
+	BotNormal: {reactionTicks: 10, viewDistance: 90, aimTolerance: 0.15, keepDistance: 30},
+	BotHard:   {reactionTicks: 3, viewDistance: 150, aimTolerance: 0.05, keepDistance: 40},
+}
+
+const (
+	// turnThreshold in radians below which a bot stops turning
+	turnThreshold = 0.05
+	// reverseTicks a bot backs off after running into something
+	reverseTicks = 20
+	// goalReached distance to a wander goal
+	goalReached = 10
+	// waypointReached distance to the next waypoint of a path
+	waypointReached = 3
+)
+
+// Bot drives a Player with a simple decision loop: wander across the map,
+// chase enemies in sight, aim the turret at them and shoot when the line of sight is clear
+type Bot struct {
+	Player   *model.Player
+	settings botSettings
+	// grid the bot finds its way around walls with, drives straight if nil
+	grid *nav.Grid
+
+	target    *model.Player
+	goal      *model.Point
+	path      []*model.Point
+	countdown int
+	reverse   int
+}
+
+// NewBot controlling a Player
+func NewBot(player *model.Player, difficulty Difficulty, grid *nav.Grid) *Bot {
+	player.Bot = true
+	return &Bot{
+		Player:   player,
+		settings: difficulties[difficulty],
+		grid:     grid,
+	}
+}
+
+// Think about the next move, returns the controls for this tick
+func (b *Bot) Think(state *model.GameState, players []*model.Player) model.Controls {
+	p := b.Player
+	if !p.IsAlive() {
+		b.target = nil
+		b.goal = nil
+		return model.Controls{}
+	}
+
+	b.countdown--
+	if b.countdown <= 0 || (b.target != nil && !b.target.IsAlive()) {
+		b.countdown = b.settings.reactionTicks
+		b.target = b.findTarget(state.Map, players)
+		if b.target != nil {
+			b.plan(b.target.Collider.Pivot)
+		}
+	}
+
+	controls := model.Controls{}
+	pivot := p.Collider.Pivot
+
+	// back off and turn after running into something
+	if p.Collider.CollisionFront && b.reverse == 0 {
+		b.reverse = reverseTicks
+		b.goal = nil
+		b.path = nil
+	}
+	if b.reverse > 0 {
+		b.reverse--
+		controls.Backward = true
+		controls.Left = true
+	} else if b.target != nil {
+		if pivot.DistanceTo(b.target.Collider.Pivot) > b.settings.keepDistance {
+			b.follow(&controls, b.target.Collider.Pivot)
+		}
+	} else {
+		if b.goal == nil || pivot.WithinDistanceOf(goalReached, b.goal) {
+			b.goal = state.Map.Spawns[state.Intn(len(state.Map.Spawns))]
+			b.plan(b.goal)
+		}
+		b.follow(&controls, b.goal)
+	}
+
+	if b.target != nil {
+		aimError := b.aim(&controls, b.target.Collider.Pivot)
+		controls.Shoot = p.Weapons[0].Ready() && math.Abs(aimError) < b.settings.aimTolerance &&
+			state.Map.LineOfSight(p.Collider.Turret, b.target.Collider.Pivot)
+	}
+	return controls
+}
+
+// findTarget returns the closest alive enemy in sight
+func (b *Bot) findTarget(m *model.Map, players []*model.Player) *model.Player {
+	pivot := b.Player.Collider.Pivot
+	var target *model.Player
+	closest := b.settings.viewDistance
+	for _, enemy := range players {
+		if enemy.ID == b.Player.ID || !enemy.IsAlive() {
+			continue
+		}
+		distance := pivot.DistanceTo(enemy.Collider.Pivot)
+		if distance < closest && m.LineOfSight(pivot, enemy.Collider.Pivot) {
+			target = enemy
+			closest = distance
+		}
+	}
+	return target
+}
+
+// plan a path to a Point
+func (b *Bot) plan(to *model.Point) {
+	b.path = nil
+	if b.grid == nil {
+		return
+	}
+	if path, ok := b.grid.FindPath(b.Player.Collider.Pivot, to); ok {
+		b.path = path
+	}
+}
+
+// follow the planned path, or drive straight to the Point without one
+func (b *Bot) follow(controls *model.Controls, to *model.Point) {
+	pivot := b.Player.Collider.Pivot
+	for len(b.path) > 0 && pivot.WithinDistanceOf(waypointReached, b.path[0]) {
+		b.path = b.path[1:]
+	}
+	if len(b.path) > 0 {
+		to = b.path[0]
+	}
+	b.steer(controls, to)
+}
+
+// steer the tank towards a Point, drives forward while roughly facing it
+func (b *Bot) steer(controls *model.Controls, to *model.Point) {
+	r := b.Player.Collider
+	delta := angleBetween(r.Pivot, r.Look, to)
+	controls.Right = delta > turnThreshold
+	controls.Left = delta < -turnThreshold
+	controls.Forward = math.Abs(delta) < math.Pi/2
+}
+
+// aim the turret at a Point, returns the remaining angle in radians
+func (b *Bot) aim(controls *model.Controls, at *model.Point) float64 {
+	r := b.Player.Collider
+	delta := angleBetween(r.Pivot, r.Turret, at)
+
+	// turning the tank turns the turret as well
+	turning := 0.0
+	if controls.Right {
+		turning = 1.5 * float64(tickTimestep)
+	}
+	if controls.Left {
+		turning = -1.5 * float64(tickTimestep)
+	}
+	remaining := delta - turning
+	controls.TurretLeft = remaining > turnThreshold
+	controls.TurretRight = remaining < -turnThreshold
+	return delta
+}
+
+// angleBetween the direction from pivot to current and from pivot to target, in [-Pi, Pi]
+func angleBetween(pivot, current, target *model.Point) float64 {
+	a := math.Atan2(float64(current.Y-pivot.Y), float64(current.X-pivot.X))
+	b := math.Atan2(float64(target.Y-pivot.Y), float64(target.X-pivot.X))
+	delta := b - a
+	for delta > math.Pi {
+		delta -= 2 * math.Pi
+	}
+	for delta < -math.Pi {
+		delta += 2 * math.Pi
+	}
+	return delta
+}
+
+// BotManager keeps the bots that fill up a game
+type BotManager struct {
+	// Fill the game with bots up to this number of Players, disabled if 0
+	Fill       int
+	Difficulty Difficulty
+	bots       []*Bot
+	grids      map[*model.Map]*nav.Grid
+	mutex      sync.Mutex
+	// filling serializes the Controller filling up the game
+	filling sync.Mutex
+}
+
+// NewBotManager ...
+func NewBotManager(fill int, difficulty Difficulty) *BotManager {
+	return &BotManager{
+		Fill:       fill,
+		Difficulty: difficulty,
+		grids:      make(map[*model.Map]*nav.Grid),
+	}
+}
+
+// Grid bots navigate a Map with, built once per Map
+func (m *BotManager) Grid(gameMap *model.Map) *nav.Grid {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	grid, ok := m.grids[gameMap]
+	if !ok {
+		grid = nav.NewGrid(gameMap, nav.DefaultCellSize)
+		m.grids[gameMap] = grid
+	}
+	return grid
+}
+
+// Bots currently in the game
+func (m *BotManager) Bots() []*Bot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	bots := make([]*Bot, len(m.bots))
+	copy(bots, m.bots)
+	return bots
+}
+
+// Missing returns how many bots have to be added, or removed if negative, for the number of humans.
+// Games without humans have no bots
+func (m *BotManager) Missing(humans int) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if humans == 0 {
+		return -len(m.bots)
+	}
+	wanted := m.Fill - humans
+	if wanted < 0 {
+		wanted = 0
+	}
+	return wanted - len(m.bots)
+}
+
+// Add a bot
+func (m *BotManager) Add(bot *Bot) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.bots = append(m.bots, bot)
+}
+
+// Remove the most recently added bot, returns nil if there is none
+func (m *BotManager) Remove() *Bot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(m.bots) == 0 {
+		return nil
+	}
+	bot := m.bots[len(m.bots)-1]
+	m.bots = m.bots[:len(m.bots)-1]
+	return bot
+}
+
+// fillBots adds or removes bots so humans always find enough opponents
+func (g *Controller) fillBots() {
+	g.bots.filling.Lock()
+	defer g.bots.filling.Unlock()
+
+	humans := 0
+	for _, p := range g.state.GetPlayers() {
+		if !p.Bot {
+			humans++
+		}
+	}
+
+	missing := g.bots.Missing(humans)
+	for ; missing > 0; missing-- {
+		g.addBot()
+	}
+	for ; missing < 0; missing++ {
+		if bot := g.bots.Remove(); bot != nil {
+			g.removePlayer(bot.Player)
+		}
+	}
+}
+
+func (g *Controller) addBot() {
+	pID := g.state.GetNewPlayerID()
+	spawn := g.state.GetRandomSpawn(g.state.GetPlayers())
+	player := model.NewPlayer(pID, spawn.X, spawn.Y, nil)
+	player.Nickname = fmt.Sprintf("Bot %d", pID)
+	bot := NewBot(player, g.bots.Difficulty, g.bots.Grid(g.state.Map))
+	g.bots.Add(bot)
+	g.state.AddPlayer(player)
+	g.record(func(r *replay.Recorder, tick uint32) {
+		r.Join(tick, player)
+	})
+	log.Printf("GameManager: %s bot %d joined, %d connected Players", g.bots.Difficulty, player.ID, g.state.GetPlayerCount())
+}
@@ -0,0 +1,94 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/awdng/triebwerk"
+	"github.com/awdng/triebwerk/auth"
+	"github.com/awdng/triebwerk/internal/testutil"
+	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDifficulty(t *testing.T) {
+	difficulty, err := ParseDifficulty("Hard")
+	assert.Nil(t, err)
+	assert.Equal(t, BotHard, difficulty)
+
+	difficulty, err = ParseDifficulty("impossible")
+	assert.NotNil(t, err)
+	assert.Equal(t, BotNormal, difficulty)
+}
+
+func TestBotShootsEnemyInSight(t *testing.T) {
+	state := model.NewGameState("test")
+	state.Map = &model.Map{Spawns: []*model.Point{{X: 0, Y: 0}}}
+	bot := NewBot(model.NewPlayer(1, 0, 0, nil), BotHard, nil)
+	enemy := model.NewPlayer(2, 20, 0, nil)
+	players := []*model.Player{bot.Player, enemy}
+
+	shot := false
+	for tick := 0; tick < 90 && !shot; tick++ {
+		controls := bot.Think(state, players)
+		assert.Equal(t, enemy, bot.target)
+		shot = controls.Shoot
+		bot.Player.Control = controls
+		bot.Player.Update(players, state, tickTimestep)
+	}
+	assert.Equal(t, true, shot)
+	assert.InDelta(t, 0, angleBetween(bot.Player.Collider.Pivot, bot.Player.Collider.Turret, enemy.Collider.Pivot), 0.05)
+
+	// walls block the line of sight
+	state.Map.Collider = []*model.Collider{{
+		Points:     []*model.Point{{X: 8, Y: -10}, {X: 12, Y: -10}, {X: 12, Y: 10}, {X: 8, Y: 10}},
+		Projectile: true,
+	}}
+	bot.countdown = 0
+	assert.Equal(t, false, bot.Think(state, players).Shoot)
+	assert.Nil(t, bot.target)
+}
+
+func TestBotFillIsCappedAtSpawns(t *testing.T) {
+	config := triebwerk.Config{Region: "test", BotFill: 100}
+	controller, transport := newTestController(t, config, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+	assert.Equal(t, len(controller.State().Map.Spawns), controller.bots.Fill)
+}
+
+func TestBotsFillGame(t *testing.T) {
+	config := triebwerk.Config{Region: "test", BotFill: 4, BotDifficulty: "easy"}
+	controller, transport := newTestController(t, config, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+
+	countBots := func() int {
+		bots := 0
+		for _, p := range controller.State().GetPlayers() {
+			if p.Bot {
+				bots++
+			}
+		}
+		return bots
+	}
+
+	first := join(t, transport, controller)
+	testutil.Eventually(t, func() bool {
+		return controller.State().GetPlayerCount() == 4 && countBots() == 3 && controller.State().InProgress()
+	})
+
+	// bots play like everyone else
+	for tick := 0; tick < 30; tick++ {
+		assert.Equal(t, true, controller.Step())
+	}
+
+	second := join(t, transport, controller)
+	testutil.Eventually(t, func() bool {
+		return controller.State().GetPlayerCount() == 4 && countBots() == 2
+	})
+
+	first.Close(0, false)
+	second.Close(0, false)
+	await(t, controller, func() bool {
+		return controller.State().GetPlayerCount() == 0
+	})
+}
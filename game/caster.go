@@ -0,0 +1,90 @@
+package game
+
+import (
+	"log"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// casterFeed serves the broadcast stream with a delay to the clients of a separate transport,
+// eg. for tournament streams that must not reveal the current game state
+type casterFeed struct {
+	transport  Transport
+	delay      time.Duration
+	ring       *delayRing
+	clients    map[*model.Client]bool
+	register   chan *model.Client
+	unregister chan *model.Client
+
+	// start is the delayed gameStart of a running game, sent to casters joining late
+	start []byte
+}
+
+// EnableDelayedBroadcast serves all broadcasts delayed by the given duration to the connections of a caster transport
+func (n *NetworkManager) EnableDelayedBroadcast(transport Transport, delay time.Duration) {
+	n.caster = &casterFeed{
+		transport:  transport,
+		delay:      delay,
+		ring:       newDelayRing(int(delay/time.Second+1) * tickrate * 2),
+		clients:    make(map[*model.Client]bool),
+		register:   make(chan *model.Client),
+		unregister: make(chan *model.Client),
+	}
+	transport.RegisterNewConnHandler(func(conn model.Connection) {
+		n.caster.register <- n.NewClient(conn)
+	})
+	// closed caster connections are detected by their reader
+	transport.UnregisterConnHandler(func(conn model.Connection) {})
+}
+
+// addCaster starts serving the delayed stream to a client, only called from the run loop
+func (n *NetworkManager) addCaster(client *model.Client) {
+	n.caster.clients[client] = true
+	go n.writer(client)
+	go n.casterReader(client)
+	if n.caster.start != nil {
+		n.enqueue(client, outboundMessage{class: Reliable, kind: gameStart, data: n.caster.start})
+	}
+	log.Printf("NetworkManager: Caster %s connected with a delay of %s, %d connected casters", client.Connection.Identifier(), n.caster.delay, len(n.caster.clients))
+}
+
+// removeCaster stops serving a client, only called from the run loop
+func (n *NetworkManager) removeCaster(client *model.Client) {
+	if _, ok := n.caster.clients[client]; ok {
+		client.Disconnect()
+		delete(n.caster.clients, client)
+		log.Printf("NetworkManager: Caster %s disconnected, %d connected casters", client.Connection.Identifier(), len(n.caster.clients))
+	}
+}
+
+// flushCasters sends all broadcasts that are older than the delay, only called from the run loop
+func (n *NetworkManager) flushCasters(now time.Time) {
+	for _, message := range n.caster.ring.pop(now.Add(-n.caster.delay)) {
+		switch message.kind {
+		case gameStart:
+			n.caster.start = message.data
+		case gameEnd:
+			n.caster.start = nil
+		}
+		for client := range n.caster.clients {
+			if !n.enqueue(client, message) {
+				n.removeCaster(client)
+			}
+		}
+	}
+}
+
+// casterReader discards everything a caster sends and detects closed connections
+func (n *NetworkManager) casterReader(client *model.Client) {
+	defer func() {
+		client.Connection.Close(writeWait, false)
+		n.caster.unregister <- client
+	}()
+	client.Connection.PrepareRead(maxMessageSize, pongWait)
+	for {
+		if _, err := client.Connection.Read(); err != nil {
+			return
+		}
+	}
+}
@@ -2,178 +2,469 @@ package game
 
 import (
 	"log"
+	"sync"
 	"time"
 
 	"github.com/awdng/triebwerk"
+	"github.com/awdng/triebwerk/auth"
+	"github.com/awdng/triebwerk/infra"
 	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/replay"
+	"github.com/awdng/triebwerk/storage"
 )
 
 const tickrate = 30
 
+// scoreboardInterval in ticks between two scoreboard broadcasts
+const scoreboardInterval = 2 * tickrate
+
+// nextMatchDelay between the end of a match and the start of the next one
+const nextMatchDelay = 10 * time.Second
+
+// tickInterval is the wall clock time between ticks, tickTimestep the simulated time of a tick in seconds
+var tickInterval = time.Duration(int(1000/tickrate)) * time.Millisecond
+var tickTimestep = float32(tickInterval/time.Millisecond) / 1000
+
 var numMeasurements int64
 var totalMeasurement int64
 var avgTickTime float64
 
-type serverState struct {
-	Connect   string            `firestore:"public_ip"`
-	Scores    map[string]int    `firestore:"scores"`
-	Names     map[string]string `firestore:"names"`
-	GameTime  int               `firestore:"gametime"`
-	UpdatedAt int64             `firestore:"updated_at"`
-}
-
 // Controller ...
 type Controller struct {
 	tickStart      time.Time
 	networkManager *NetworkManager
-	playerManager  *PlayerManager
+	authenticator  auth.Authenticator
 	state          *model.GameState
-	firebase       *triebwerk.Firebase
+	store          storage.Store
 	masterServer   MasterServerClient
+	sessions       *SessionStore
+	bots           *BotManager
+	pending        *pendingPlayers
+	authTimeout    time.Duration
+
+	// worker calls the master server and auth providers off the game loop,
+	// their results are posted back as events that the game loop applies
+	worker *infra.Worker
+	events chan func()
+	// disconnected connections, queued by the network run loop which must never wait for the game loop
+	disconnected      []model.Connection
+	disconnectedMutex sync.Mutex
+	stop              chan struct{}
+	// loops are the goroutines started by Init, closed stops them once
+	loops  sync.WaitGroup
+	closed sync.Once
+	// nextMatch starts once this time has passed, after the break that follows a match
+	nextMatch time.Time
+
+	// tick of the running game, replay events are recorded with it
+	tick          uint32
+	replayDir     string
+	recorder      *replay.Recorder
+	recorderMutex sync.Mutex
+
+	// clock is set when the game is stepped manually instead of by the game loop
+	clock *model.ManualClock
 }
 
 // MasterServerClient ...
 type MasterServerClient interface {
-	Init(address string)
+	Init(address string) error
 	GetServerState()
-	SendHeartbeat(*model.GameState)
-	EndGame(*model.GameState)
-	AuthorizePlayer(string, *model.Player) error
+	SendHeartbeat(*infra.ServerSnapshot) error
+	EndGame(*infra.ServerSnapshot) error
 }
 
 // NewController creates a game instance
-func NewController(region string, networkManager *NetworkManager, playerManager *PlayerManager, firebase *triebwerk.Firebase, masterServer MasterServerClient) *Controller {
-	return &Controller{
+func NewController(config triebwerk.Config, networkManager *NetworkManager, authenticator auth.Authenticator, store storage.Store, masterServer MasterServerClient) *Controller {
+	difficulty, err := ParseDifficulty(config.BotDifficulty)
+	if err != nil {
+		log.Printf("GameManager: %s, using %s bots", err, difficulty)
+	}
+	state := model.NewGameState(config.Region)
+	fill := config.BotFill
+	if spawns := len(state.Map.Spawns); fill > spawns {
+		log.Printf("GameManager: Bot fill of %d exceeds the %d spawns of the map, filling up to %d players", fill, spawns, spawns)
+		fill = spawns
+	}
+	g := &Controller{
 		networkManager: networkManager,
-		playerManager:  playerManager,
-		state:          model.NewGameState(region),
-		firebase:       firebase,
+		authenticator:  authenticator,
+		state:          state,
+		store:          store,
 		masterServer:   masterServer,
+		sessions:       NewSessionStore(config.SessionGracePeriod),
+		bots:           NewBotManager(fill, difficulty),
+		pending:        newPendingPlayers(),
+		authTimeout:    config.AuthTimeout,
+		worker:         infra.NewWorker(config.InfraQueueSize, config.InfraWorkers),
+		events:         make(chan func(), eventQueueSize),
+		stop:           make(chan struct{}),
+		replayDir:      config.ReplayDir,
 	}
+	return g
+}
+
+// EnableManualStepping makes the game advance only by calls to Step, with a clock that moves
+// one tick per step and a seeded rng, so the same inputs always produce the same game
+func (g *Controller) EnableManualStepping(clock *model.ManualClock, seed int64) {
+	g.clock = clock
+	g.state.SetClock(clock)
+	g.state.Seed(seed)
 }
 
-// RegisterPlayer registers a networked Player
+// State of the game
+func (g *Controller) State() *model.GameState {
+	return g.state
+}
+
+// RegisterPlayer registers a networked Player, or a spectator if requested by the connection
 func (g *Controller) RegisterPlayer(conn model.Connection) {
-	players := g.state.GetPlayers()
-	pID := g.state.GetNewPlayerID()
-	spawn := g.state.Map.GetRandomSpawn(players)
-	player := model.NewPlayer(pID, spawn.X, spawn.Y, conn)
-	g.networkManager.Register(player, g.state)
+	if conn.Params().Get(roleParam) == roleSpectator {
+		// the game loop iterates the spectators, they are added between ticks
+		g.post(func() {
+			g.registerSpectator(conn)
+		})
+		return
+	}
+
+	if token := conn.Params().Get(resumeParam); token != "" {
+		if player := g.sessions.Resume(token); player != nil {
+			// the game loop reads the client of the Player, it is swapped between ticks
+			g.post(func() {
+				g.resumePlayer(player, conn)
+			})
+			return
+		}
+	}
+
+	// the Player joins the game once it is authorized
+	player := model.NewPlayer(g.state.GetNewPlayerID(), 0, 0, g.networkManager.NewClient(conn))
+	g.pending.add(player)
+	g.networkManager.Accept(player.Client)
+	go g.authenticate(player)
+}
+
+// joinPlayer adds an authorized Player to the game
+func (g *Controller) joinPlayer(player *model.Player) {
+	player.Respawn(g.state.GetRandomSpawn(g.state.GetPlayers()))
+	if err := g.sessions.Create(player); err != nil {
+		log.Printf("GameManager: Could not create session for Player %d: %s", player.ID, err)
+	}
+	if !g.networkManager.Register(player, g.state) {
+		log.Printf("GameManager: Player %d disconnected before joining", player.ID)
+		g.sessions.Remove(player)
+		return
+	}
 	g.state.AddPlayer(player)
+	g.record(func(r *replay.Recorder, tick uint32) {
+		r.Join(tick, player)
+	})
+	g.saveProfile(player)
+	g.loadRating(player)
+	g.fillBots()
 	g.CheckStartConditions()
-	if g.state.InProgress() { // game already started, new Player has to know about it
-		g.networkManager.SendGameStartToClient(player.Client, g.state)
-	}
 	log.Printf("GameManager: Player %d connected, %d connected Players", player.ID, g.state.GetPlayerCount())
 }
 
-// UnregisterPlayer of a networked game
+// resumePlayer binds a new connection to the Player of a resumed session
+func (g *Controller) resumePlayer(player *model.Player, conn model.Connection) {
+	player.Client = g.networkManager.NewClient(conn)
+	g.networkManager.Accept(player.Client)
+	if !g.networkManager.Register(player, g.state) {
+		log.Printf("GameManager: Player %d disconnected before resuming its session", player.ID)
+		g.disconnectPlayer(player)
+		return
+	}
+	log.Printf("GameManager: Player %d resumed its session, %d connected Players", player.ID, g.state.GetPlayerCount())
+}
+
+// UnregisterPlayer of a networked game, the Player stays frozen in the game until its session expires.
+// It is called from the network run loop, the connection is queued without blocking and
+// unregistered after the posted events
 func (g *Controller) UnregisterPlayer(conn model.Connection) {
+	g.disconnectedMutex.Lock()
+	defer g.disconnectedMutex.Unlock()
+	g.disconnected = append(g.disconnected, conn)
+}
+
+func (g *Controller) unregisterPlayer(conn model.Connection) {
+	if g.pending.remove(conn) {
+		log.Printf("GameManager: Connection %s closed before authentication", conn.Identifier())
+		return
+	}
+	for _, s := range g.state.GetSpectators() {
+		if s.Client.Connection == conn {
+			g.state.RemoveSpectator(s)
+			log.Printf("GameManager: Spectator %d disconnected", s.ID)
+			return
+		}
+	}
+
 	players := g.state.GetPlayers()
 	for _, p := range players {
 		if p.Client.Connection == conn {
-			g.state.RemovePlayer(p)
-			log.Printf("GameManager: Player %d disconnected, %d connected Players", p.ID, g.state.GetPlayerCount())
+			g.disconnectPlayer(p)
 			break
 		}
 	}
 }
 
+// disconnectPlayer freezes the Player in the game until its session expires, or removes it right away
+func (g *Controller) disconnectPlayer(p *model.Player) {
+	p.Control = model.Controls{}
+	expire := func(p *model.Player) {
+		g.post(func() {
+			g.removePlayer(p)
+		})
+	}
+	if g.sessions.Disconnect(p, expire) {
+		log.Printf("GameManager: Player %d disconnected, session can be resumed", p.ID)
+		return
+	}
+	g.removePlayer(p)
+}
+
+func (g *Controller) removePlayer(p *model.Player) {
+	g.state.RemovePlayer(p)
+	g.record(func(r *replay.Recorder, tick uint32) {
+		r.Leave(tick, p)
+	})
+	if g.state.InProgress() { // stats of a finished game are saved with its end
+		g.saveMatchStats(p, false, false)
+	}
+	log.Printf("GameManager: Player %d disconnected, %d connected Players", p.ID, g.state.GetPlayerCount())
+
+	// spectators following the Player fall back to a free camera
+	for _, s := range g.state.GetSpectators() {
+		if s.Follow == p.ID {
+			s.Follow = 0
+			g.networkManager.SendFollow(s, g.state)
+		}
+	}
+
+	if !p.Bot {
+		g.fillBots()
+	}
+}
+
 // Init the gameserver
 func (g *Controller) Init() error {
 	// init HeartBeat
-	go g.HeartBeat()
+	g.loops.Add(2)
+	go func() {
+		defer g.loops.Done()
+		g.HeartBeat()
+	}()
+	go func() {
+		defer g.loops.Done()
+		g.gameLoop()
+	}()
 
 	// Start networking
 	return g.networkManager.Start()
 }
 
+// Close stops the game loop and waits until the calls to external services that are still queued are done,
+// so the results of the last game are saved before the store is closed. Their events are not applied anymore
+func (g *Controller) Close() {
+	g.closed.Do(func() {
+		close(g.stop)
+		g.loops.Wait()
+		g.worker.Stop()
+	})
+}
+
 // HeartBeat ...
 func (g *Controller) HeartBeat() {
 	// Wait for Network to become ready
-	time.Sleep(time.Second)
+	select {
+	case <-time.After(time.Second):
+	case <-g.stop:
+		return
+	}
 
 	// log.Printf("GameManager: Server Registered with global ID %s", server.ID)
 
 	ticker := time.NewTicker(time.Second * 5)
-	g.masterServer.Init(g.networkManager.GetAddress())
-	for range ticker.C {
-		g.masterServer.SendHeartbeat(g.state)
+	defer ticker.Stop()
+	g.submit("register_server", func() error {
+		return g.masterServer.Init(g.networkManager.GetAddress())
+	}, nil)
+	for {
+		select {
+		case <-ticker.C:
+		case <-g.stop:
+			return
+		}
+		// the state is read by the game loop, never concurrently with a tick
+		g.post(func() {
+			snapshot := infra.Snapshot(g.state)
+			g.submit("heartbeat", func() error {
+				return g.masterServer.SendHeartbeat(snapshot)
+			}, nil)
+			g.saveServerState()
+		})
 	}
 }
 
-// CheckStartConditions the gameserver loop
+// CheckStartConditions starts the next game once the break after the last one is over
 func (g *Controller) CheckStartConditions() {
+	if g.clock == nil && time.Now().Before(g.nextMatch) {
+		return
+	}
 	if g.state.ReadyToStart() {
 		g.state.Start()
-		// Execute game loop
-		go g.gameLoop()
+		g.startRecording()
+		g.begin()
+	}
+}
+
+// Step the game by one tick when stepping manually, returns false once the game has ended.
+// Posted events are applied before the tick like in the game loop
+func (g *Controller) Step() bool {
+	if g.clock == nil {
+		return false
+	}
+	g.ApplyEvents()
+	if !g.state.InProgress() {
+		return false
 	}
+	g.clock.Advance(tickInterval)
+	if !g.step() {
+		g.finish()
+		return false
+	}
+	return true
 }
 
 func (g *Controller) processInputs(p *model.Player, players []*model.Player, timestep float32) {
+	// at most one scoreboard per tick, however often it was requested
+	scoreboardRequested := false
 	// read control input
 	for len(p.Client.NetworkIn) != 0 {
 		message := <-p.Client.NetworkIn
 		switch messageType := message.MessageType; messageType {
-		case 0:
-			token := message.Body.(string)
-			err := g.masterServer.AuthorizePlayer(token, p)
-			// err := g.playerManager.Authorize(p, token)
-			if err != nil {
-				log.Printf("GameManager: Player %d (%s) could not be authorized, forcing disconnect: %s", p.ID, p.GlobalID, err)
-				g.networkManager.ForceDisconnect(p)
-				continue
-			}
-			log.Printf("GameManager: Player %d authorized successfully as GlobalID %s %s", p.ID, p.GlobalID, p.Nickname)
 		case 1:
 			// make sure all input gets processed
 			p.Control = message.Body.(model.Controls)
+			g.record(func(r *replay.Recorder, tick uint32) {
+				r.Input(tick, p, p.Control)
+			})
 			p.Update(players, g.state, timestep)
 		case 5:
-			g.networkManager.SendTime(p, g.state, &message)
+			g.networkManager.SendTime(p.ID, p.Client, g.state, &message)
+		case 11:
+			scoreboardRequested = true
 		}
 	}
+	if scoreboardRequested {
+		g.networkManager.SendScoreboard(p.ID, p.Client, g.state)
+	}
 	if len(p.Client.NetworkIn) > 1 {
 		log.Printf("WARNING: GameManager: Applied more than 1 input for Player %d with GlobalID %s", p.ID, p.GlobalID)
 	}
 }
 
+// gameLoop is the only goroutine that changes the game: every tick it applies the posted events
+// and steps the game while it is in progress, the next game starts after a break
 func (g *Controller) gameLoop() {
-	interval := time.Duration(int(1000/tickrate)) * time.Millisecond
-	timestep := float32(interval/time.Millisecond) / 1000
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-g.stop:
+			return
+		}
+		g.ApplyEvents()
+		if !g.state.InProgress() {
+			g.CheckStartConditions()
+			continue
+		}
+
+		g.tickStart = time.Now()
+		if !g.step() {
+			g.finish()
+			g.nextMatch = time.Now().Add(nextMatchDelay)
+			continue
+		}
+
+		// measure average tick time
+		numMeasurements++
+		totalMeasurement += time.Now().UTC().UnixNano() - g.tickStart.UTC().UnixNano()
+		avgTickTime = float64(totalMeasurement/numMeasurements) / 1000 / 1000
+	}
+}
 
-	ticker := time.NewTicker(interval)
+func (g *Controller) begin() {
 	g.networkManager.BroadcastGameStart(g.state)
 	log.Printf("GameManager: Game has started")
-	for range ticker.C {
-		g.tickStart = time.Now()
-		players := g.state.GetPlayers()
+}
+
+// step the game by one fixed timestep, returns false once the game has ended
+func (g *Controller) step() bool {
+	tick := g.nextTick()
+	players := g.state.GetPlayers()
 
-		// apply latest client inputs
-		for _, p := range players {
-			g.processInputs(p, players, timestep)
-			p.HandleRespawn(g.state)
+	// bots decide on their input like clients
+	for _, bot := range g.bots.Bots() {
+		bot.Player.Client.NetworkIn <- model.NetworkMessage{
+			MessageType: 1,
+			Body:        bot.Think(g.state, players),
 		}
+	}
 
-		// broadcast game state to clients
-		g.networkManager.BroadcastGameState(g.state)
+	// apply latest client inputs
+	for _, p := range players {
+		if !g.sessions.IsDisconnected(p) {
+			p.Stats.PlayTime += tickInterval
+		}
+		g.processInputs(p, players, tickTimestep)
+		alive := p.IsAlive()
+		p.HandleRespawn(g.state)
+		if !alive && p.IsAlive() {
+			g.record(func(r *replay.Recorder, tick uint32) {
+				r.Spawn(tick, p)
+			})
+		}
+	}
+	for _, s := range g.state.GetSpectators() {
+		g.processSpectatorInputs(s)
+	}
 
-		if g.state.HasEnded() {
-			break
+	// announce the kills of this tick in the kill feed
+	for _, p := range players {
+		for _, kill := range p.TakeKills() {
+			g.networkManager.BroadcastKill(kill, g.state)
 		}
+	}
 
-		// measure average tick time
-		numMeasurements++
-		totalMeasurement += time.Now().UTC().UnixNano() - g.tickStart.UTC().UnixNano()
-		avgTickTime = float64(totalMeasurement/numMeasurements) / 1000 / 1000
+	if tick%keyframeInterval == 0 {
+		g.record(func(r *replay.Recorder, tick uint32) {
+			r.Keyframe(tick, players)
+		})
 	}
-	ticker.Stop()
 
+	// broadcast game state to clients
+	g.networkManager.BroadcastGameState(g.state)
+	if tick%scoreboardInterval == 0 {
+		g.networkManager.BroadcastScoreboard(g.state)
+	}
+
+	return !g.state.HasEnded()
+}
+
+func (g *Controller) finish() {
 	g.state.End()
+	g.stopRecording()
 	log.Printf("GameManager: Game has ended")
 	g.networkManager.BroadcastGameEnd(g.state)
-	g.masterServer.EndGame(g.state)
-	time.Sleep(10 * time.Second)
-	g.CheckStartConditions()
+	g.networkManager.BroadcastResults(g.state.Results(nextMatchDelay), g.state)
+	snapshot := infra.Snapshot(g.state)
+	g.submit("end_game", func() error {
+		return g.masterServer.EndGame(snapshot)
+	}, nil)
+	g.saveHighscores()
+	g.saveStats()
+	g.saveRatings()
 }
@@ -0,0 +1,371 @@
+package game
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awdng/triebwerk"
+	"github.com/awdng/triebwerk/auth"
+	"github.com/awdng/triebwerk/infra"
+	"github.com/awdng/triebwerk/internal/testutil"
+	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/protocol"
+	"github.com/awdng/triebwerk/rating"
+	"github.com/awdng/triebwerk/storage"
+	"github.com/awdng/triebwerk/transport/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMasterServer struct{}
+
+func (testMasterServer) Init(address string) error                 { return nil }
+func (testMasterServer) GetServerState()                           {}
+func (testMasterServer) SendHeartbeat(*infra.ServerSnapshot) error { return nil }
+func (testMasterServer) EndGame(*infra.ServerSnapshot) error       { return nil }
+
+// join the game with a new authenticated connection, waits until the Player is added
+func join(t *testing.T, transport *memory.Transport, controller *Controller) *memory.Connection {
+	humans := func() int {
+		count := 0
+		for _, p := range controller.State().GetPlayers() {
+			if !p.Bot {
+				count++
+			}
+		}
+		return count
+	}
+	before := humans()
+	conn := transport.Dial(nil)
+	assert.Nil(t, conn.Write(protocol.EncodePlayerAuth(0, "tank")))
+	await(t, controller, func() bool {
+		return humans() == before+1
+	})
+	return conn
+}
+
+// await applies the events of a manually stepped controller until the condition is met,
+// like the game loop would between ticks
+func await(t *testing.T, controller *Controller, condition func() bool) {
+	t.Helper()
+	testutil.Eventually(t, func() bool {
+		controller.ApplyEvents()
+		return condition()
+	})
+}
+
+// newTestController of a manually stepped game that is served on a memory transport
+func newTestController(t *testing.T, config triebwerk.Config, authenticator auth.Authenticator, store storage.Store, master MasterServerClient) (*Controller, *memory.Transport) {
+	t.Helper()
+	transport := memory.NewTransport(memory.Options{})
+	networkManager := NewNetworkManager(transport, protocol.NewBinaryProtocol())
+	controller := NewController(config, networkManager, authenticator, store, master)
+	controller.EnableManualStepping(model.NewManualClock(time.Unix(1500000000, 0)), 1)
+	transport.RegisterNewConnHandler(controller.RegisterPlayer)
+	transport.UnregisterConnHandler(controller.UnregisterPlayer)
+	go networkManager.Start()
+	return controller, transport
+}
+
+func TestManualSteppingIsReproducible(t *testing.T) {
+	simulate := func() []model.Point {
+		controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+		defer transport.Close()
+
+		for i := 0; i < 3; i++ {
+			join(t, transport, controller)
+		}
+		testutil.Eventually(t, controller.State().InProgress)
+
+		for tick := 0; tick < 90; tick++ {
+			for _, p := range controller.State().GetPlayers() {
+				p.Client.NetworkIn <- model.NetworkMessage{
+					MessageType: 1,
+					Body:        model.Controls{Forward: true, Right: (tick/15+p.ID)%2 == 0, Shoot: tick%5 == 0},
+				}
+			}
+			assert.Equal(t, true, controller.Step())
+		}
+		assert.Equal(t, uint32(90*tickInterval/time.Millisecond), controller.State().GameTime())
+
+		positions := []model.Point{}
+		for _, p := range controller.State().GetPlayers() {
+			positions = append(positions, *p.Collider.Pivot)
+		}
+		return positions
+	}
+
+	assert.Equal(t, simulate(), simulate())
+}
+
+// blockingMasterServer never answers before it is released
+type blockingMasterServer struct {
+	testMasterServer
+	release chan struct{}
+}
+
+func (m blockingMasterServer) EndGame(*infra.ServerSnapshot) error {
+	<-m.release
+	return nil
+}
+
+// blockingAuthenticator authorizes a token once it is released
+type blockingAuthenticator struct {
+	release chan struct{}
+}
+
+func (a blockingAuthenticator) Authorize(token string, player *model.Player) error {
+	if token == "slow" {
+		<-a.release
+	}
+	player.Nickname = token
+	return nil
+}
+
+func TestSlowInfraDoesNotBlockGame(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, blockingAuthenticator{release}, storage.NewMemory(), blockingMasterServer{release: release})
+	defer transport.Close()
+
+	join(t, transport, controller)
+	testutil.Eventually(t, controller.State().InProgress)
+
+	// the game goes on while a Player waits for its authorization
+	slow := transport.Dial(nil)
+	assert.Nil(t, slow.Write(protocol.EncodePlayerAuth(0, "slow")))
+	steps := 0
+	for controller.Step() {
+		steps++
+	}
+	assert.Equal(t, int(controller.State().Length()/tickInterval), steps)
+	assert.Equal(t, 1, controller.State().GetPlayerCount())
+}
+
+func TestStatsAreSavedAtGameEnd(t *testing.T) {
+	store := storage.NewMemory()
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, auth.NewAnonymous(), store, testMasterServer{})
+	defer transport.Close()
+
+	join(t, transport, controller)
+	testutil.Eventually(t, controller.State().InProgress)
+	player := controller.State().GetPlayers()[0]
+	for tick := 0; controller.Step(); tick++ {
+		player.Client.NetworkIn <- model.NetworkMessage{MessageType: 1, Body: model.Controls{Shoot: tick%60 == 0}}
+	}
+
+	var stats *storage.LifetimeStats
+	testutil.Eventually(t, func() bool {
+		stats, _ = store.Stats(player.GlobalID)
+		return stats != nil
+	})
+	assert.Equal(t, "tank", stats.Nickname)
+	assert.Equal(t, 1, stats.Matches)
+	assert.Equal(t, 0, stats.Wins)
+	assert.Equal(t, player.Stats.ShotsFired, stats.ShotsFired)
+	assert.True(t, stats.ShotsFired > 0)
+	// the last tick may end a little after the game length
+	assert.InDelta(t, float64(controller.State().Length()), float64(stats.PlayTime), float64(tickInterval))
+}
+
+func TestRatingsAreSavedAtGameEnd(t *testing.T) {
+	store := storage.NewMemory()
+	assert.Nil(t, store.SaveRatings([]storage.Rating{{GlobalID: "anonymous-1", Rating: 1600, Matches: 3}}))
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, auth.NewAnonymous(), store, testMasterServer{})
+	defer transport.Close()
+
+	join(t, transport, controller)
+	join(t, transport, controller)
+	testutil.Eventually(t, controller.State().InProgress)
+	var winner, loser *model.Player
+	for _, p := range controller.State().GetPlayers() {
+		switch p.GlobalID {
+		case "anonymous-1":
+			winner = p
+		case "anonymous-2":
+			loser = p
+		}
+	}
+	await(t, controller, func() bool {
+		return winner.Rating == 1600
+	})
+	assert.Equal(t, rating.Default, loser.Rating)
+	for controller.Step() {
+		winner.Score = 2
+		loser.Score = 1
+	}
+
+	testutil.Eventually(t, func() bool {
+		ratings, _ := store.Ratings([]string{"anonymous-1"})
+		return ratings["anonymous-1"].Matches == 4
+	})
+	ratings, err := store.Ratings([]string{"anonymous-1", "anonymous-2"})
+	assert.Nil(t, err)
+	assert.True(t, ratings["anonymous-1"].Rating > 1600)
+	assert.True(t, ratings["anonymous-2"].Rating < rating.Default)
+	assert.Equal(t, 1, ratings["anonymous-2"].Matches)
+	await(t, controller, func() bool {
+		return winner.Rating == ratings["anonymous-1"].Rating
+	})
+}
+
+func TestScoreboardAndResults(t *testing.T) {
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+
+	conn := join(t, transport, controller)
+	testutil.Eventually(t, controller.State().InProgress)
+	var mutex sync.Mutex
+	var scoreboards int
+	var results *model.Results
+	go func() {
+		for {
+			data, err := conn.Read()
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			if _, ok := protocol.DecodeScoreboard(data); ok {
+				scoreboards++
+			}
+			if r, ok := protocol.DecodeResults(data); ok {
+				results = &r
+			}
+			mutex.Unlock()
+		}
+	}()
+
+	// scoreboards are sent on request
+	player := controller.State().GetPlayers()[0]
+	player.Client.NetworkIn <- model.NetworkMessage{MessageType: 11}
+	controller.Step()
+	testutil.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return scoreboards == 1
+	})
+
+	// and periodically
+	for i := 0; i < scoreboardInterval; i++ {
+		controller.Step()
+	}
+	testutil.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return scoreboards == 2
+	})
+
+	player.Score = 3
+	for controller.Step() {
+	}
+	testutil.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return results != nil
+	})
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, player.ID, results.Winner)
+	assert.Equal(t, nextMatchDelay, results.NextMatch)
+	assert.Equal(t, []model.ScoreboardEntry{{ID: player.ID, Nickname: "tank", Placement: 1, Score: 3}}, results.Scoreboard)
+}
+
+func TestUnregisterDoesNotWaitForGameLoop(t *testing.T) {
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+	conn := join(t, transport, controller)
+
+	// the game loop is busy and does not apply events, the network run loop still unregisters
+	for i := 0; i < eventQueueSize; i++ {
+		controller.post(func() {})
+	}
+	conn.Close(0, false)
+	testutil.Eventually(t, func() bool {
+		controller.disconnectedMutex.Lock()
+		defer controller.disconnectedMutex.Unlock()
+		return len(controller.disconnected) == 1
+	})
+
+	controller.ApplyEvents()
+	assert.Equal(t, 0, controller.State().GetPlayerCount())
+}
+
+func TestCloseWaitsForQueuedJobs(t *testing.T) {
+	release := make(chan struct{})
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, auth.NewAnonymous(), storage.NewMemory(), blockingMasterServer{release: release})
+	defer transport.Close()
+
+	join(t, transport, controller)
+	for controller.Step() {
+	}
+
+	// the end of the game is still reported to the master server
+	closed := make(chan struct{})
+	go func() {
+		controller.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Closed before the queued job was done")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+	controller.Close()
+}
+
+func TestResumeAndExpireSession(t *testing.T) {
+	config := triebwerk.Config{Region: "test", SessionGracePeriod: 50 * time.Millisecond}
+	controller, transport := newTestController(t, config, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+
+	conn := join(t, transport, controller)
+	player := controller.State().GetPlayers()[0]
+	original := player.Client.Connection
+	conn.Close(0, false)
+	await(t, controller, func() bool {
+		return controller.sessions.IsDisconnected(player)
+	})
+
+	// the new connection is bound to the Player by the game loop
+	resumed := transport.Dial(url.Values{resumeParam: {player.ResumeToken}})
+	await(t, controller, func() bool {
+		return !controller.sessions.IsDisconnected(player) && player.Client.Connection != original
+	})
+	assert.True(t, controller.Step())
+	assert.Equal(t, 1, controller.State().GetPlayerCount())
+
+	// the expired session is removed by the game loop too
+	resumed.Close(0, false)
+	await(t, controller, func() bool {
+		return controller.State().GetPlayerCount() == 0
+	})
+}
+
+func TestResumeStartsRunningGame(t *testing.T) {
+	config := triebwerk.Config{Region: "test", SessionGracePeriod: time.Minute}
+	controller, transport := newTestController(t, config, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+
+	conn := join(t, transport, controller)
+	player := controller.State().GetPlayers()[0]
+	assert.True(t, controller.State().InProgress())
+	conn.Close(0, false)
+	await(t, controller, func() bool {
+		return controller.sessions.IsDisconnected(player)
+	})
+
+	// the resumed client receives the start of the running game right after the confirmation
+	resumed := transport.Dial(url.Values{resumeParam: {player.ResumeToken}})
+	await(t, controller, func() bool {
+		return !controller.sessions.IsDisconnected(player)
+	})
+	message, err := resumed.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(register), message[1])
+	message, err = resumed.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(gameStart), message[1])
+}
@@ -0,0 +1,60 @@
+package game
+
+import "time"
+
+// delayedMessage is a broadcast waiting for its delay to pass
+type delayedMessage struct {
+	at      time.Time
+	message outboundMessage
+}
+
+// delayRing is a time-indexed ring buffer of broadcasts, it grows instead of overwriting
+// so no message of the delayed stream is lost
+type delayRing struct {
+	items []delayedMessage
+	start int
+	count int
+}
+
+func newDelayRing(capacity int) *delayRing {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &delayRing{
+		items: make([]delayedMessage, capacity),
+	}
+}
+
+// push a message broadcast at the given time, times have to be ascending
+func (r *delayRing) push(at time.Time, message outboundMessage) {
+	if r.count == len(r.items) {
+		items := make([]delayedMessage, len(r.items)*2)
+		for i := 0; i < r.count; i++ {
+			items[i] = r.items[(r.start+i)%len(r.items)]
+		}
+		r.items = items
+		r.start = 0
+	}
+	r.items[(r.start+r.count)%len(r.items)] = delayedMessage{at: at, message: message}
+	r.count++
+}
+
+// pop all messages broadcast until the given time
+func (r *delayRing) pop(until time.Time) []outboundMessage {
+	messages := make([]outboundMessage, 0)
+	for r.count > 0 {
+		item := r.items[r.start]
+		if item.at.After(until) {
+			break
+		}
+		messages = append(messages, item.message)
+		r.items[r.start] = delayedMessage{}
+		r.start = (r.start + 1) % len(r.items)
+		r.count--
+	}
+	return messages
+}
+
+func (r *delayRing) len() int {
+	return r.count
+}
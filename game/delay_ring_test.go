@@ -0,0 +1,31 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelayRing(t *testing.T) {
+	ring := newDelayRing(2)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		ring.push(start.Add(time.Duration(i)*time.Second), outboundMessage{data: []byte{byte(i)}})
+	}
+	assert.Equal(t, 5, ring.len())
+
+	assert.Equal(t, 0, len(ring.pop(start.Add(-time.Second))))
+
+	messages := ring.pop(start.Add(2 * time.Second))
+	assert.Equal(t, 3, len(messages))
+	assert.Equal(t, []byte{0}, messages[0].data)
+	assert.Equal(t, []byte{2}, messages[2].data)
+
+	// ring wraps around after popping
+	ring.push(start.Add(5*time.Second), outboundMessage{data: []byte{5}})
+	messages = ring.pop(start.Add(10 * time.Second))
+	assert.Equal(t, 3, len(messages))
+	assert.Equal(t, []byte{5}, messages[2].data)
+	assert.Equal(t, 0, ring.len())
+}
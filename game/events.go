@@ -0,0 +1,56 @@
+package game
+
+import (
+	"log"
+
+	"github.com/awdng/triebwerk/infra"
+)
+
+// eventQueueSize of the Controller, results of infra jobs wait here until they are applied
+const eventQueueSize = 256
+
+// ApplyEvents applies all posted events on the calling goroutine. The game loop applies them at the
+// start of every tick, so events never change the game concurrently with the simulation.
+// When stepping manually it has to be called between steps, eg. to let authorized Players join
+func (g *Controller) ApplyEvents() {
+	// the game loop is the only receiver, a queued event is never taken by someone else
+	for len(g.events) > 0 {
+		event := <-g.events
+		event()
+	}
+
+	// connections are unregistered after the events, a connection registered before it closed is known by now
+	g.disconnectedMutex.Lock()
+	disconnected := g.disconnected
+	g.disconnected = nil
+	g.disconnectedMutex.Unlock()
+	for _, conn := range disconnected {
+		g.unregisterPlayer(conn)
+	}
+}
+
+// post an event to be applied by the game loop, events posted after the Controller closed are dropped
+func (g *Controller) post(event func()) {
+	select {
+	case g.events <- event:
+	case <-g.stop:
+	}
+}
+
+// submit a call to an external service to the worker, done is posted as event with the result.
+// Returns false if the worker is overloaded and the call was dropped
+func (g *Controller) submit(name string, run func() error, done func(err error)) bool {
+	job := infra.Job{Name: name, Run: run}
+	if done != nil {
+		job.Done = func(err error) {
+			g.post(func() {
+				done(err)
+			})
+		}
+	}
+	if err := g.worker.Submit(job); err != nil {
+		log.Printf("GameManager: Dropped %s call: %s", name, err)
+		return false
+	}
+	return true
+}
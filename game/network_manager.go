@@ -33,8 +33,49 @@ const (
 	serverTime
 	gameStart
 	gameEnd
+	follow
+	disconnect
+	killFeed
+	scoreboard
+	results
 )
 
+// MessageClass decides how a message is queued for a client
+type MessageClass uint8
+
+const (
+	// Reliable messages like game events are delivered in order and never dropped,
+	// a client that can not keep up is disconnected
+	Reliable MessageClass = iota
+	// Unreliable messages like state updates are superseded by the next one,
+	// under backpressure the oldest queued update is dropped
+	Unreliable
+	// Latest messages like the scoreboard have a slot of their own, a newer message replaces
+	// the queued one but state updates never push it out
+	Latest
+)
+
+// outboundMessage is an encoded message with its delivery class
+type outboundMessage struct {
+	class MessageClass
+	kind  MessageType
+	data  []byte
+}
+
+// directMessage to a single connected client
+type directMessage struct {
+	client  *model.Client
+	message outboundMessage
+}
+
+// joinRequest of an accepted client, joined reports if the client is still connected
+type joinRequest struct {
+	client       *model.Client
+	confirmation []byte
+	start        []byte // gameStart of a running game, nil otherwise
+	joined       chan bool
+}
+
 // Protocol that encodes/decodes data for network transfer
 type Protocol interface {
 	Encode(id int, currentGameTime uint32, message *model.NetworkMessage) []byte
@@ -59,20 +100,36 @@ type NetworkManager struct {
 	// network context eg. websockets
 	transport Transport
 
+	// additional network contexts served alongside, eg. UDP
+	transports []Transport
+
+	// delayed broadcast for casters, nil if disabled
+	caster *casterFeed
+
 	// protocol that encodes/decodes data for network transfer
 	protocol Protocol
 
-	// Registered clients.
+	// Registered clients, only clients that joined the game receive broadcasts.
 	clients map[*model.Client]bool
 
-	// Inbound messages from the clients.
-	broadcast chan []byte
+	// Outbound messages to all clients.
+	broadcast chan outboundMessage
+
+	// Outbound messages to a single client.
+	direct chan directMessage
 
 	// Register requests from the clients.
 	register chan *model.Client
 
+	// Join requests of accepted clients that may receive broadcasts.
+	join chan joinRequest
+
 	// Unregister requests from clients.
 	unregister chan *model.Client
+
+	// queue sizes of new clients
+	reliableQueueSize   int
+	unreliableQueueSize int
 }
 
 // NewNetworkManager ...
@@ -81,66 +138,233 @@ func NewNetworkManager(transport Transport, protocol Protocol) *NetworkManager {
 		Ready:      false,
 		transport:  transport,
 		protocol:   protocol,
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan outboundMessage),
+		direct:     make(chan directMessage),
 		register:   make(chan *model.Client),
+		join:       make(chan joinRequest),
 		unregister: make(chan *model.Client),
 		clients:    make(map[*model.Client]bool),
+
+		reliableQueueSize:   model.DefaultReliableQueueSize,
+		unreliableQueueSize: model.DefaultUnreliableQueueSize,
 	}
 }
 
+// SetQueueSizes of clients connecting from now on
+func (n *NetworkManager) SetQueueSizes(reliable, unreliable int) {
+	n.reliableQueueSize = reliable
+	n.unreliableQueueSize = unreliable
+}
+
+// NewClient for a connection with the queue sizes of the NetworkManager
+func (n *NetworkManager) NewClient(conn model.Connection) *model.Client {
+	return model.NewClient(conn, n.reliableQueueSize, n.unreliableQueueSize)
+}
+
 // GetAddress ...
 func (n *NetworkManager) GetAddress() string {
 	return n.transport.GetAddress()
 }
 
+// AddTransport serves an additional network context alongside the main transport,
+// its connection handlers have to be registered with the same callbacks
+func (n *NetworkManager) AddTransport(transport Transport) {
+	n.transports = append(n.transports, transport)
+}
+
 // Start handling network connections
 func (n *NetworkManager) Start() error {
 	n.transport.Init()
+	for _, transport := range n.transports {
+		transport.Init()
+		go func(transport Transport) {
+			log.Printf("NetworkManager: Transport %s stopped: %s", transport.GetAddress(), transport.Run())
+		}(transport)
+	}
+	if n.caster != nil {
+		n.caster.transport.Init()
+		go func() {
+			log.Printf("NetworkManager: Caster transport %s stopped: %s", n.caster.transport.GetAddress(), n.caster.transport.Run())
+		}()
+	}
 	go n.run()
 	return n.transport.Run()
 }
 
 func (n *NetworkManager) run() {
 	log.Printf("NetworkManager: Listening for incoming Network traffic ...")
+
+	// casters are served from the same loop, the channels stay nil if the delayed broadcast is disabled
+	var casterRegister, casterUnregister chan *model.Client
+	var casterFlush <-chan time.Time
+	if n.caster != nil {
+		casterRegister = n.caster.register
+		casterUnregister = n.caster.unregister
+		ticker := time.NewTicker(time.Second / tickrate)
+		defer ticker.Stop()
+		casterFlush = ticker.C
+	}
+
 	for {
 		select {
+		case client := <-casterRegister:
+			n.addCaster(client)
+		case client := <-casterUnregister:
+			n.removeCaster(client)
+		case now := <-casterFlush:
+			n.flushCasters(now)
 		case client := <-n.register:
-			n.clients[client] = true
-			go n.writer(client)
-			go n.reader(client)
-			log.Printf("NetworkManager: Client %s connected, %d connected clients ", client.Connection.Identifier(), len(n.clients))
+			n.connect(client)
+		case request := <-n.join:
+			request.joined <- n.joinClient(request)
 		case client := <-n.unregister:
-			if _, ok := n.clients[client]; ok {
-				client.Disconnect()
-				delete(n.clients, client)
-				log.Printf("NetworkManager: Client %s disconnected, %d connected clients ", client.Connection.Identifier(), len(n.clients))
-				n.transport.Unregister(client.Connection)
-			}
+			n.disconnect(client)
 		case message := <-n.broadcast:
-			for client := range n.clients {
-				// select is used to avoid blocking when a network output writer of a client is not ready
-				// client is disconnectet if network output channel buffer reaches maximum size
-				select {
-				case client.NetworkOut <- message:
-				default:
-					log.Printf("NetworkManager: Closing connection of Client %s: Could not write to NetworkOut channel, buffer size %d", client.Connection.Identifier(), len(client.NetworkOut))
-					n.unregister <- client
+			for client, joined := range n.clients {
+				if !joined {
+					continue
 				}
+				if !n.enqueue(client, message) {
+					n.disconnect(client)
+				}
+			}
+			if n.caster != nil {
+				n.caster.ring.push(time.Now(), message)
+			}
+		case direct := <-n.direct:
+			// the client may have disconnected since the message was sent, its channels are closed then
+			if _, ok := n.clients[direct.client]; ok && !n.enqueue(direct.client, direct.message) {
+				n.disconnect(direct.client)
+			}
+		}
+	}
+}
+
+// enqueue a message for a client, select is used to avoid blocking when a network output writer of a client is not ready.
+// Returns false if the client can not keep up and has to be disconnected.
+func (n *NetworkManager) enqueue(client *model.Client, message outboundMessage) bool {
+	if message.class != Reliable {
+		queue := client.StateOut
+		if message.class == Latest {
+			queue = client.LatestOut
+		}
+		for {
+			select {
+			case queue <- message.data:
+				return true
+			default:
+			}
+			// latest state wins, drop the oldest queued update
+			select {
+			case <-queue:
+			default:
 			}
 		}
 	}
+
+	// client is disconnected if reliable network output channel buffer reaches maximum size
+	select {
+	case client.NetworkOut <- message.data:
+		return true
+	default:
+		log.Printf("NetworkManager: Closing connection of Client %s: Could not write to NetworkOut channel, buffer size %d", client.Connection.Identifier(), len(client.NetworkOut))
+		return false
+	}
+}
+
+// connect a client that has not joined the game yet, only called from the run loop
+func (n *NetworkManager) connect(client *model.Client) {
+	n.clients[client] = false
+	go n.writer(client)
+	go n.reader(client)
+	log.Printf("NetworkManager: Client %s connected, %d connected clients ", client.Connection.Identifier(), len(n.clients))
+}
+
+// joinClient sends the confirmation and the start of a running game to an accepted client,
+// which receives broadcasts from now on.
+// Returns false if the client disconnected in the meantime, only called from the run loop
+func (n *NetworkManager) joinClient(request joinRequest) bool {
+	if _, ok := n.clients[request.client]; !ok {
+		return false
+	}
+	n.clients[request.client] = true
+	if !n.enqueue(request.client, outboundMessage{class: Reliable, kind: register, data: request.confirmation}) {
+		n.disconnect(request.client)
+		return false
+	}
+	if request.start != nil && !n.enqueue(request.client, outboundMessage{class: Reliable, kind: gameStart, data: request.start}) {
+		n.disconnect(request.client)
+		return false
+	}
+	return true
 }
 
-// Register a new Client with the NetworkService
-func (n *NetworkManager) Register(player *model.Player, state *model.GameState) {
-	n.register <- player.Client
+// disconnect a client, only called from the run loop
+func (n *NetworkManager) disconnect(client *model.Client) {
+	if _, ok := n.clients[client]; ok {
+		client.Disconnect()
+		delete(n.clients, client)
+		log.Printf("NetworkManager: Client %s disconnected, %d connected clients ", client.Connection.Identifier(), len(n.clients))
+		n.transport.Unregister(client.Connection)
+	}
+}
 
+// Accept a new Client, it can send and receive messages but gets no broadcasts before it joins
+func (n *NetworkManager) Accept(client *model.Client) {
+	n.register <- client
+}
+
+// Reject a Client with a reason, the connection is closed after the reason was sent
+func (n *NetworkManager) Reject(client *model.Client, reason string) {
+	n.Send(client, n.protocol.Encode(0, 0, &model.NetworkMessage{
+		MessageType: uint8(disconnect),
+		Body:        reason,
+	}))
+	time.AfterFunc(writeWait, func() {
+		client.Connection.Close(writeWait, false)
+	})
+}
+
+// Register an accepted Client with the NetworkService, the Client joins the game.
+// Returns false if the Client disconnected before
+func (n *NetworkManager) Register(player *model.Player, state *model.GameState) bool {
 	// send registration confirmation to client
-	buf := make([]byte, 0)
-	buf = append(buf, n.protocol.Encode(player.ID, state.GameTime(), &model.NetworkMessage{
+	return n.joinGame(player.Client, n.protocol.Encode(player.ID, state.GameTime(), &model.NetworkMessage{
+		MessageType: uint8(register),
+		Body:        player.ResumeToken,
+	}), state)
+}
+
+// RegisterSpectator with the NetworkService, spectators receive all broadcasts
+func (n *NetworkManager) RegisterSpectator(spectator *model.Spectator, state *model.GameState) bool {
+	// send registration confirmation to client, spectators can not resume sessions
+	if !n.joinGame(spectator.Client, n.protocol.Encode(spectator.ID, state.GameTime(), &model.NetworkMessage{
 		MessageType: uint8(register),
-	})...)
-	n.Send(player.Client, buf)
+	}), state) {
+		return false
+	}
+	n.SendFollow(spectator, state)
+	return true
+}
+
+// joinGame lets the run loop confirm the registration, a game already in progress is started right after
+func (n *NetworkManager) joinGame(client *model.Client, confirmation []byte, state *model.GameState) bool {
+	request := joinRequest{client: client, confirmation: confirmation, joined: make(chan bool, 1)}
+	if state.InProgress() {
+		request.start = n.protocol.Encode(0, state.GameTime(), &model.NetworkMessage{
+			MessageType: uint8(gameStart),
+		})
+	}
+	n.join <- request
+	return <-request.joined
+}
+
+// SendFollow confirms the Player a spectator follows
+func (n *NetworkManager) SendFollow(spectator *model.Spectator, state *model.GameState) {
+	n.Send(spectator.Client, n.protocol.Encode(spectator.ID, state.GameTime(), &model.NetworkMessage{
+		MessageType: uint8(follow),
+		Body:        spectator.Follow,
+	}))
 }
 
 // ForceDisconnect of Player
@@ -150,16 +374,17 @@ func (n *NetworkManager) ForceDisconnect(player *model.Player) {
 	n.unregister <- client
 }
 
-// SendTime back to player
-func (n *NetworkManager) SendTime(player *model.Player, state *model.GameState, message *model.NetworkMessage) {
+// SendTime back to a player or spectator
+func (n *NetworkManager) SendTime(id int, client *model.Client, state *model.GameState, message *model.NetworkMessage) {
 	buf := make([]byte, 0)
-	buf = append(buf, n.protocol.Encode(player.ID, state.GameTime(), message)...)
-	n.Send(player.Client, buf)
+	buf = append(buf, n.protocol.Encode(id, state.GameTime(), message)...)
+	n.Send(client, buf)
 }
 
-// Send data to a client
+// Send data to a client, the message is dropped if the client disconnected meanwhile.
+// Only the run loop writes to the channels of a client, it closes them on disconnect
 func (n *NetworkManager) Send(client *model.Client, message []byte) error {
-	client.NetworkOut <- message
+	n.direct <- directMessage{client: client, message: outboundMessage{class: Reliable, data: message}}
 	return nil
 }
 
@@ -174,7 +399,7 @@ func (n *NetworkManager) BroadcastGameState(state *model.GameState) {
 		})...)
 	}
 	if len(buf) > 0 {
-		n.broadcast <- buf
+		n.broadcast <- outboundMessage{class: Unreliable, kind: position, data: buf}
 	}
 }
 
@@ -185,33 +410,58 @@ func (n *NetworkManager) BroadcastGameStart(state *model.GameState) {
 	})
 
 	if len(buf) > 0 {
-		n.broadcast <- buf
+		n.broadcast <- outboundMessage{class: Reliable, kind: gameStart, data: buf}
 	}
 }
 
-// SendGameStartToClient ...
-func (n *NetworkManager) SendGameStartToClient(client *model.Client, state *model.GameState) {
+// BroadcastGameEnd ...
+func (n *NetworkManager) BroadcastGameEnd(state *model.GameState) {
 	buf := n.protocol.Encode(0, state.GameTime(), &model.NetworkMessage{
-		MessageType: uint8(gameStart),
+		MessageType: uint8(gameEnd),
 	})
 
 	if len(buf) > 0 {
-		n.Send(client, buf)
+		n.broadcast <- outboundMessage{class: Reliable, kind: gameEnd, data: buf}
 	}
 }
 
-// BroadcastGameEnd ...
-func (n *NetworkManager) BroadcastGameEnd(state *model.GameState) {
+// BroadcastKill to the kill feed of all clients
+func (n *NetworkManager) BroadcastKill(kill model.Kill, state *model.GameState) {
 	buf := n.protocol.Encode(0, state.GameTime(), &model.NetworkMessage{
-		MessageType: uint8(gameEnd),
+		MessageType: uint8(killFeed),
+		Body:        kill,
+	})
+	n.broadcast <- outboundMessage{class: Reliable, kind: killFeed, data: buf}
+}
+
+// SendScoreboard to a Player or spectator that requested it, it replaces a scoreboard that is still queued
+func (n *NetworkManager) SendScoreboard(id int, client *model.Client, state *model.GameState) {
+	buf := n.protocol.Encode(id, state.GameTime(), &model.NetworkMessage{
+		MessageType: uint8(scoreboard),
+		Body:        state.Scoreboard(),
 	})
+	n.direct <- directMessage{client: client, message: outboundMessage{class: Latest, kind: scoreboard, data: buf}}
+}
 
-	if len(buf) > 0 {
-		n.broadcast <- buf
-	}
+// BroadcastScoreboard periodically, it replaces a scoreboard that is still queued
+func (n *NetworkManager) BroadcastScoreboard(state *model.GameState) {
+	buf := n.protocol.Encode(0, state.GameTime(), &model.NetworkMessage{
+		MessageType: uint8(scoreboard),
+		Body:        state.Scoreboard(),
+	})
+	n.broadcast <- outboundMessage{class: Latest, kind: scoreboard, data: buf}
+}
+
+// BroadcastResults of a finished match, sent after the game end
+func (n *NetworkManager) BroadcastResults(matchResults model.Results, state *model.GameState) {
+	buf := n.protocol.Encode(0, state.GameTime(), &model.NetworkMessage{
+		MessageType: uint8(results),
+		Body:        matchResults,
+	})
+	n.broadcast <- outboundMessage{class: Reliable, kind: results, data: buf}
 }
 
-// Writer constantly reads messages from the players NetworkOut and sends it to the websocket connection.
+// Writer constantly reads messages from the players NetworkOut and StateOut and sends it to the websocket connection.
 //
 // A goroutine running Writer is started for each connection. The
 // application ensures that there is at most one writer to a connection by
@@ -223,19 +473,27 @@ func (n *NetworkManager) writer(client *model.Client) {
 		client.Connection.Close(writeWait, false)
 	}()
 	for {
+		// reliable messages take priority over state updates
 		select {
 		case message, ok := <-client.NetworkOut:
-			client.Connection.PrepareWrite(writeWait)
-			if !ok {
-				// The NetworkManager closed the channel.
-				log.Printf("Writer: Could not read NetworkOut Channel of Client %s", client.Connection.Identifier())
-				client.Connection.Close(writeWait, true)
+			if !n.write(client, message, ok, Reliable) {
 				return
 			}
+			continue
+		default:
+		}
 
-			err := client.Connection.Write(message)
-			if err != nil {
-				log.Printf("Writer: Closing connection for Client %s: %s", client.Connection.Identifier(), err)
+		select {
+		case message, ok := <-client.NetworkOut:
+			if !n.write(client, message, ok, Reliable) {
+				return
+			}
+		case message, ok := <-client.StateOut:
+			if !n.write(client, message, ok, Unreliable) {
+				return
+			}
+		case message, ok := <-client.LatestOut:
+			if !n.write(client, message, ok, Latest) {
 				return
 			}
 		case <-ticker.C:
@@ -244,6 +502,29 @@ func (n *NetworkManager) writer(client *model.Client) {
 	}
 }
 
+// write a message to the connection of a client, returns false if the connection has to be closed
+func (n *NetworkManager) write(client *model.Client, message []byte, ok bool, class MessageClass) bool {
+	client.Connection.PrepareWrite(writeWait)
+	if !ok {
+		// The NetworkManager closed the channel.
+		log.Printf("Writer: Could not read NetworkOut Channel of Client %s", client.Connection.Identifier())
+		client.Connection.Close(writeWait, true)
+		return false
+	}
+
+	var err error
+	if writer, unreliable := client.Connection.(model.UnreliableWriter); unreliable && class == Unreliable {
+		err = writer.WriteUnreliable(message)
+	} else {
+		err = client.Connection.Write(message)
+	}
+	if err != nil {
+		log.Printf("Writer: Closing connection for Client %s: %s", client.Connection.Identifier(), err)
+		return false
+	}
+	return true
+}
+
 // Reader constantly reads messages from the websocket connection and passes them to the NetworkManager.
 //
 // The application runs reader in a per-connection goroutine. The application
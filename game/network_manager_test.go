@@ -0,0 +1,157 @@
+package game
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/protocol"
+	"github.com/awdng/triebwerk/transport/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkManagerRoundtrip(t *testing.T) {
+	transport := memory.NewTransport(memory.Options{})
+	defer transport.Close()
+	networkManager := NewNetworkManager(transport, protocol.NewBinaryProtocol())
+	state := model.NewGameState("test")
+
+	var player *model.Player
+	unregistered := make(chan model.Connection, 1)
+	transport.RegisterNewConnHandler(func(conn model.Connection) {
+		player = model.NewPlayer(state.GetNewPlayerID(), 0, 0, networkManager.NewClient(conn))
+		networkManager.Accept(player.Client)
+		networkManager.Register(player, state)
+	})
+	transport.UnregisterConnHandler(func(conn model.Connection) {
+		unregistered <- conn
+	})
+	go networkManager.Start()
+
+	client := transport.Dial(nil)
+
+	// registration confirmation carries the player id
+	message, err := client.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(player.ID), message[0])
+	assert.Equal(t, byte(register), message[1])
+
+	// inputs are decoded and passed to the player
+	input := []byte{0, 1, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(input[9:], 7)
+	assert.Nil(t, client.Write(input))
+	received := <-player.Client.NetworkIn
+	controls := received.Body.(model.Controls)
+	assert.Equal(t, true, controls.Forward)
+	assert.Equal(t, true, controls.Shoot)
+	assert.Equal(t, uint32(7), controls.Sequence)
+
+	client.Close(0, false)
+	assert.Equal(t, player.Client.Connection, <-unregistered)
+}
+
+func TestRegisterStartsRunningGame(t *testing.T) {
+	transport := memory.NewTransport(memory.Options{})
+	defer transport.Close()
+	networkManager := NewNetworkManager(transport, protocol.NewBinaryProtocol())
+	state := model.NewGameState("test")
+	state.Start()
+
+	transport.RegisterNewConnHandler(func(conn model.Connection) {
+		player := model.NewPlayer(state.GetNewPlayerID(), 0, 0, networkManager.NewClient(conn))
+		networkManager.Accept(player.Client)
+		networkManager.Register(player, state)
+	})
+	go networkManager.Start()
+
+	// clients joining a running game receive its start right after the confirmation
+	client := transport.Dial(nil)
+	message, err := client.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(register), message[1])
+	message, err = client.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(gameStart), message[1])
+}
+
+func TestUnreliableMessagesAreCoalesced(t *testing.T) {
+	networkManager := NewNetworkManager(memory.NewTransport(memory.Options{}), protocol.NewBinaryProtocol())
+	networkManager.SetQueueSizes(0, 5)
+	client := networkManager.NewClient(nil)
+	networkManager.clients[client] = true
+	assert.Equal(t, model.DefaultReliableQueueSize, cap(client.NetworkOut))
+	assert.Equal(t, 5, cap(client.StateOut))
+
+	for i := 0; i < 10; i++ {
+		networkManager.enqueue(client, outboundMessage{class: Unreliable, data: []byte{byte(i)}})
+	}
+	networkManager.enqueue(client, outboundMessage{class: Reliable, data: []byte{42}})
+
+	// only the latest state updates are kept, reliable messages are queued separately
+	assert.Equal(t, cap(client.StateOut), len(client.StateOut))
+	var latest []byte
+	for len(client.StateOut) > 0 {
+		latest = <-client.StateOut
+	}
+	assert.Equal(t, []byte{9}, latest)
+	assert.Equal(t, []byte{42}, <-client.NetworkOut)
+	assert.Equal(t, true, networkManager.clients[client])
+}
+
+func TestScoreboardsReplaceEachOther(t *testing.T) {
+	transport := memory.NewTransport(memory.Options{})
+	defer transport.Close()
+	networkManager := NewNetworkManager(transport, protocol.NewBinaryProtocol())
+	state := model.NewGameState("test")
+	state.AddPlayer(model.NewPlayer(1, 0, 0, nil))
+	var client *model.Client
+	transport.RegisterNewConnHandler(func(conn model.Connection) {
+		client = networkManager.NewClient(conn)
+	})
+	transport.UnregisterConnHandler(func(conn model.Connection) {})
+	transport.Dial(nil)
+	// the client joined without a writer, so everything sent to it stays queued
+	networkManager.clients[client] = true
+	go networkManager.run()
+
+	networkManager.SendScoreboard(1, client, state)
+	networkManager.BroadcastScoreboard(state)
+	for i := 0; i < 10; i++ {
+		networkManager.BroadcastGameState(state)
+	}
+	networkManager.SendScoreboard(1, client, state)
+
+	// only the latest scoreboard is queued, state updates do not push it out
+	assert.Equal(t, 1, len(client.LatestOut))
+	assert.Equal(t, 0, len(client.NetworkOut))
+
+	// a scoreboard requested by a client that disconnected meanwhile is dropped
+	networkManager.unregister <- client
+	networkManager.SendScoreboard(1, client, state)
+}
+
+func TestDelayedBroadcast(t *testing.T) {
+	transport := memory.NewTransport(memory.Options{})
+	defer transport.Close()
+	casterTransport := memory.NewTransport(memory.Options{})
+	networkManager := NewNetworkManager(transport, protocol.NewBinaryProtocol())
+	networkManager.EnableDelayedBroadcast(casterTransport, 50*time.Millisecond)
+	go networkManager.Start()
+
+	caster := casterTransport.Dial(nil)
+	state := model.NewGameState("test")
+	start := time.Now()
+	networkManager.BroadcastGameStart(state)
+
+	message, err := caster.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(gameStart), message[1])
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// casters joining a running game receive its start first
+	late := casterTransport.Dial(nil)
+	message, err = late.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(gameStart), message[1])
+}
@@ -0,0 +1,187 @@
+package game
+
+import (
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/rating"
+	"github.com/awdng/triebwerk/storage"
+)
+
+// saveProfile of an authorized Player, players without GlobalID are not persisted
+func (g *Controller) saveProfile(player *model.Player) {
+	if player.GlobalID == "" {
+		return
+	}
+	globalID, nickname := player.GlobalID, player.Nickname
+	g.submit("save_profile", func() error {
+		now := time.Now()
+		profile, err := g.store.Profile(globalID)
+		if err == storage.ErrNotFound {
+			profile, err = &storage.Profile{GlobalID: globalID, CreatedAt: now}, nil
+		}
+		if err != nil {
+			return err
+		}
+		profile.Nickname = nickname
+		profile.LastSeen = now
+		return g.store.SaveProfile(profile)
+	}, nil)
+}
+
+// saveHighscores of a finished game, the scores are taken before the next game resets them
+func (g *Controller) saveHighscores() {
+	now := time.Now()
+	highscores := []storage.Highscore{}
+	for _, p := range g.state.GetPlayers() {
+		if p.Bot || p.GlobalID == "" {
+			continue
+		}
+		highscores = append(highscores, storage.Highscore{GlobalID: p.GlobalID, Nickname: p.Nickname, Score: p.Score, At: now})
+	}
+	if len(highscores) == 0 {
+		return
+	}
+	g.submit("save_highscores", func() error {
+		return g.store.AddHighscores(highscores)
+	}, nil)
+}
+
+// saveServerState with the current scores of the game
+func (g *Controller) saveServerState() {
+	state := &storage.ServerState{
+		ID:        g.networkManager.GetAddress(),
+		Address:   g.networkManager.GetAddress(),
+		Region:    g.state.Region,
+		Scores:    make(map[string]int),
+		Names:     make(map[string]string),
+		Ratings:   make(map[string]float64),
+		GameTime:  int(g.state.GameTime()),
+		UpdatedAt: time.Now(),
+	}
+	for _, p := range g.state.GetPlayers() {
+		if p.GlobalID == "" {
+			continue
+		}
+		state.Scores[p.GlobalID] = p.Score
+		state.Names[p.GlobalID] = p.Nickname
+		state.Ratings[p.GlobalID] = p.Rating
+	}
+	g.submit("save_server_state", func() error {
+		return g.store.SaveServerState(state)
+	}, nil)
+}
+
+// saveStats of all Players at the end of a match, the Players with the highest score win
+func (g *Controller) saveStats() {
+	players := g.state.GetPlayers()
+	best := 0
+	for _, p := range players {
+		if p.Score > best {
+			best = p.Score
+		}
+	}
+	for _, p := range players {
+		g.saveMatchStats(p, true, best > 0 && p.Score == best)
+	}
+}
+
+// saveMatchStats of a Player to its lifetime stats, matches only count if the Player stayed until the end
+func (g *Controller) saveMatchStats(p *model.Player, finished bool, won bool) {
+	if p.Bot || p.GlobalID == "" {
+		return
+	}
+	match := storage.LifetimeStats{
+		GlobalID:    p.GlobalID,
+		Nickname:    p.Nickname,
+		Kills:       p.Stats.Kills,
+		Deaths:      p.Stats.Deaths,
+		ShotsFired:  p.Stats.ShotsFired,
+		Hits:        p.Stats.Hits,
+		Assists:     p.Stats.Assists,
+		DamageDealt: p.Stats.DamageDealt,
+		PlayTime:    p.Stats.PlayTime,
+		UpdatedAt:   time.Now(),
+	}
+	if finished {
+		match.Matches = 1
+	}
+	if won {
+		match.Wins = 1
+	}
+	g.submit("save_stats", func() error {
+		return g.store.AddStats(match)
+	}, nil)
+}
+
+// loadRating of an authorized Player, it plays with the default rating until the rating is loaded
+func (g *Controller) loadRating(player *model.Player) {
+	if player.GlobalID == "" {
+		return
+	}
+	globalID := player.GlobalID
+	var stored map[string]storage.Rating
+	g.submit("load_rating", func() error {
+		var err error
+		stored, err = g.store.Ratings([]string{globalID})
+		return err
+	}, func(err error) {
+		if loaded, ok := stored[globalID]; err == nil && ok {
+			player.Rating = loaded.Rating
+		}
+	})
+}
+
+// saveRatings of the Players from their placements by score in a finished match
+func (g *Controller) saveRatings() {
+	rated := make(map[string]*model.Player)
+	for _, p := range g.state.GetPlayers() {
+		if p.Bot || p.GlobalID == "" {
+			continue
+		}
+		rated[p.GlobalID] = p
+	}
+	if len(rated) < 2 {
+		return
+	}
+
+	scores := make(map[string]int, len(rated))
+	for globalID, p := range rated {
+		scores[globalID] = p.Score
+	}
+	placements := rating.Placements(scores)
+	results := make([]rating.Result, 0, len(rated))
+	ratings := make([]storage.Rating, 0, len(rated))
+	globalIDs := make([]string, 0, len(rated))
+	for globalID, p := range rated {
+		results = append(results, rating.Result{ID: globalID, Placement: placements[globalID]})
+		ratings = append(ratings, storage.Rating{GlobalID: globalID, Nickname: p.Nickname})
+		globalIDs = append(globalIDs, globalID)
+	}
+
+	g.submit("save_ratings", func() error {
+		stored, err := g.store.Ratings(globalIDs)
+		if err != nil {
+			return err
+		}
+		current := make(map[string]float64, len(stored))
+		for globalID, r := range stored {
+			current[globalID] = r.Rating
+		}
+		updated := rating.Update(current, results)
+		now := time.Now()
+		for i := range ratings {
+			ratings[i].Rating = updated[ratings[i].GlobalID]
+			ratings[i].Matches = stored[ratings[i].GlobalID].Matches + 1
+			ratings[i].UpdatedAt = now
+		}
+		return g.store.SaveRatings(ratings)
+	}, func(err error) {
+		if err != nil {
+			return
+		}
+		for _, r := range ratings {
+			rated[r.GlobalID].Rating = r.Rating
+		}
+	})
+}
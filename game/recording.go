@@ -0,0 +1,76 @@
+package game
+
+import (
+	"log"
+
+	"github.com/awdng/triebwerk/replay"
+)
+
+// keyframeInterval in ticks between replay keyframes
+const keyframeInterval = tickrate
+
+// startRecording a replay of the game that just started, all Players join at their spawn
+func (g *Controller) startRecording() {
+	g.recorderMutex.Lock()
+	g.tick = 0
+	g.recorderMutex.Unlock()
+	if g.replayDir == "" {
+		return
+	}
+
+	recorder := replay.NewRecorder(replay.Header{
+		MapID:            g.state.Map.ID,
+		Region:           g.state.Region,
+		StartedAt:        g.state.Clock().Now(),
+		Tickrate:         tickrate,
+		Timestep:         tickTimestep,
+		GameLength:       g.state.Length(),
+		KeyframeInterval: keyframeInterval,
+	})
+	for _, p := range g.state.GetPlayers() {
+		recorder.Join(0, p)
+	}
+
+	g.recorderMutex.Lock()
+	defer g.recorderMutex.Unlock()
+	g.recorder = recorder
+}
+
+// nextTick of the running game
+func (g *Controller) nextTick() uint32 {
+	g.recorderMutex.Lock()
+	defer g.recorderMutex.Unlock()
+	g.tick++
+	return g.tick
+}
+
+// record an event with the current tick if a replay is recorded, events are
+// recorded under the same lock that advances the tick so their ticks never decrease
+func (g *Controller) record(event func(r *replay.Recorder, tick uint32)) {
+	g.recorderMutex.Lock()
+	defer g.recorderMutex.Unlock()
+
+	if g.recorder != nil {
+		event(g.recorder, g.tick)
+	}
+}
+
+// stopRecording and save the replay of the game that just ended
+func (g *Controller) stopRecording() {
+	g.recorderMutex.Lock()
+	recorder := g.recorder
+	g.recorder = nil
+	tick := g.tick
+	g.recorderMutex.Unlock()
+
+	if recorder == nil {
+		return
+	}
+	recorder.End(tick)
+	path, err := recorder.Save(g.replayDir)
+	if err != nil {
+		log.Printf("GameManager: Could not save replay: %s", err)
+		return
+	}
+	log.Printf("GameManager: Replay saved to %s", path)
+}
@@ -0,0 +1,114 @@
+package game
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// resumeParam is the connection param a client passes its resume token with
+const resumeParam = "resume"
+
+// session of a player that can be resumed with its token after a disconnect
+type session struct {
+	player       *model.Player
+	disconnected bool
+	expiry       *time.Timer
+}
+
+// SessionStore keeps the sessions of all players in the game
+type SessionStore struct {
+	gracePeriod time.Duration
+	sessions    map[string]*session
+	mutex       sync.Mutex
+}
+
+// NewSessionStore creates a SessionStore, disconnected players are kept for the grace period
+func NewSessionStore(gracePeriod time.Duration) *SessionStore {
+	return &SessionStore{
+		gracePeriod: gracePeriod,
+		sessions:    make(map[string]*session),
+	}
+}
+
+// Create a session for a player and issue its resume token
+func (s *SessionStore) Create(player *model.Player) error {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	player.ResumeToken = hex.EncodeToString(buf)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sessions[player.ResumeToken] = &session{player: player}
+	return nil
+}
+
+// Disconnect keeps the session of a player for the grace period, expire is called on a timer goroutine if it
+// is not resumed in time. Disconnecting a disconnected session keeps its expiry.
+// Returns false if the session can not be resumed and the player has to be removed right away.
+func (s *SessionStore) Disconnect(player *model.Player, expire func(player *model.Player)) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current, ok := s.sessions[player.ResumeToken]
+	if !ok || s.gracePeriod <= 0 {
+		delete(s.sessions, player.ResumeToken)
+		return false
+	}
+	if current.disconnected {
+		return true
+	}
+	current.disconnected = true
+	current.expiry = time.AfterFunc(s.gracePeriod, func() {
+		s.mutex.Lock()
+		if !current.disconnected || s.sessions[player.ResumeToken] != current {
+			s.mutex.Unlock()
+			return
+		}
+		delete(s.sessions, player.ResumeToken)
+		s.mutex.Unlock()
+		expire(player)
+	})
+	return true
+}
+
+// Resume the disconnected session of token, returns nil if there is none
+func (s *SessionStore) Resume(token string) *model.Player {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current, ok := s.sessions[token]
+	if !ok || !current.disconnected {
+		return nil
+	}
+	current.expiry.Stop()
+	current.disconnected = false
+	return current.player
+}
+
+// Remove the session of a player, it can not be resumed anymore
+func (s *SessionStore) Remove(player *model.Player) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if current, ok := s.sessions[player.ResumeToken]; ok {
+		if current.expiry != nil {
+			current.expiry.Stop()
+		}
+		delete(s.sessions, player.ResumeToken)
+	}
+}
+
+// IsDisconnected returns true if the player lost its connection and the session is waiting to be resumed
+func (s *SessionStore) IsDisconnected(player *model.Player) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current, ok := s.sessions[player.ResumeToken]
+	return ok && current.disconnected
+}
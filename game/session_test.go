@@ -0,0 +1,46 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionResume(t *testing.T) {
+	sessions := NewSessionStore(time.Minute)
+	player := model.NewPlayer(1, 0, 0, nil)
+	assert.Nil(t, sessions.Create(player))
+	assert.Equal(t, 32, len(player.ResumeToken))
+
+	// connected sessions can not be taken over
+	assert.Nil(t, sessions.Resume(player.ResumeToken))
+
+	assert.Equal(t, true, sessions.Disconnect(player, func(*model.Player) {
+		t.Error("session must not expire")
+	}))
+	assert.Equal(t, true, sessions.IsDisconnected(player))
+	assert.Equal(t, true, sessions.Disconnect(player, nil))
+	assert.Equal(t, player, sessions.Resume(player.ResumeToken))
+	assert.Equal(t, false, sessions.IsDisconnected(player))
+	assert.Nil(t, sessions.Resume("unknown"))
+}
+
+func TestSessionExpiry(t *testing.T) {
+	sessions := NewSessionStore(10 * time.Millisecond)
+	player := model.NewPlayer(1, 0, 0, nil)
+	assert.Nil(t, sessions.Create(player))
+
+	expired := make(chan *model.Player, 1)
+	sessions.Disconnect(player, func(p *model.Player) {
+		expired <- p
+	})
+	assert.Equal(t, player, <-expired)
+	assert.Nil(t, sessions.Resume(player.ResumeToken))
+
+	// removed sessions are not kept after a disconnect
+	assert.Nil(t, sessions.Create(player))
+	sessions.Remove(player)
+	assert.Equal(t, false, sessions.Disconnect(player, nil))
+}
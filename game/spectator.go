@@ -0,0 +1,66 @@
+package game
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+const (
+	// roleParam is the connection param a client selects its role with
+	roleParam     = "role"
+	roleSpectator = "spectator"
+
+	// followParam is the connection param with the ID of the Player a spectator follows
+	followParam = "follow"
+)
+
+// registerSpectator registers a networked spectator, it receives the game state but has no tank
+func (g *Controller) registerSpectator(conn model.Connection) {
+	follow, _ := strconv.Atoi(conn.Params().Get(followParam))
+	if !g.isPlayer(follow) {
+		follow = 0
+	}
+
+	spectator := model.NewSpectator(g.state.GetNewPlayerID(), follow, g.networkManager.NewClient(conn))
+	g.networkManager.Accept(spectator.Client)
+	if !g.networkManager.RegisterSpectator(spectator, g.state) {
+		log.Printf("GameManager: Spectator %d disconnected before joining", spectator.ID)
+		return
+	}
+	g.state.AddSpectator(spectator)
+	log.Printf("GameManager: Spectator %d connected, following Player %d", spectator.ID, spectator.Follow)
+}
+
+func (g *Controller) processSpectatorInputs(s *model.Spectator) {
+	// at most one scoreboard per tick, however often it was requested
+	scoreboardRequested := false
+	for len(s.Client.NetworkIn) != 0 {
+		message := <-s.Client.NetworkIn
+		switch messageType := message.MessageType; messageType {
+		case 5:
+			g.networkManager.SendTime(s.ID, s.Client, g.state, &message)
+		case 8:
+			s.Follow = message.Body.(int)
+			if !g.isPlayer(s.Follow) {
+				s.Follow = 0
+			}
+			g.networkManager.SendFollow(s, g.state)
+		case 11:
+			scoreboardRequested = true
+		}
+	}
+	if scoreboardRequested {
+		g.networkManager.SendScoreboard(s.ID, s.Client, g.state)
+	}
+}
+
+func (g *Controller) isPlayer(id int) bool {
+	for _, p := range g.state.GetPlayers() {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package game
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/awdng/triebwerk"
+	"github.com/awdng/triebwerk/auth"
+	"github.com/awdng/triebwerk/internal/testutil"
+	"github.com/awdng/triebwerk/model"
+	"github.com/awdng/triebwerk/protocol"
+	"github.com/awdng/triebwerk/storage"
+	"github.com/awdng/triebwerk/transport/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// spectate the game with a new connection that follows a Player, waits until the spectator is added
+func spectate(t *testing.T, transport *memory.Transport, controller *Controller, follow int) (*memory.Connection, *model.Spectator) {
+	before := len(controller.State().GetSpectators())
+	conn := transport.Dial(url.Values{roleParam: {roleSpectator}, followParam: {strconv.Itoa(follow)}})
+	// spectators get the whole game, discard it
+	go func() {
+		for {
+			if _, err := conn.Read(); err != nil {
+				return
+			}
+		}
+	}()
+	await(t, controller, func() bool {
+		return len(controller.State().GetSpectators()) == before+1
+	})
+	spectators := controller.State().GetSpectators()
+	return conn, spectators[len(spectators)-1]
+}
+
+func TestSpectatorsAreNoPlayers(t *testing.T) {
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+
+	// a spectator of an unknown Player starts with a free camera
+	_, spectator := spectate(t, transport, controller, 42)
+	assert.Equal(t, 0, spectator.Follow)
+	assert.Equal(t, 0, controller.State().GetPlayerCount())
+	assert.False(t, controller.State().ReadyToStart())
+	controller.CheckStartConditions()
+	assert.False(t, controller.State().InProgress())
+
+	join(t, transport, controller)
+	assert.Equal(t, 1, controller.State().GetPlayerCount())
+	assert.True(t, controller.State().InProgress())
+}
+
+func TestSpectatorFollowsPlayer(t *testing.T) {
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+
+	first := join(t, transport, controller)
+	join(t, transport, controller)
+	players := controller.State().GetPlayers()
+	conn, spectator := spectate(t, transport, controller, players[0].ID)
+	assert.Equal(t, players[0].ID, spectator.Follow)
+
+	// the camera switches between Players, unknown Players are not followed
+	assert.Nil(t, conn.Write(protocol.EncodeSpectatorFollow(spectator.ID, players[1].ID)))
+	await(t, controller, func() bool {
+		controller.Step()
+		return spectator.Follow == players[1].ID
+	})
+	assert.Nil(t, conn.Write(protocol.EncodeSpectatorFollow(spectator.ID, 99)))
+	await(t, controller, func() bool {
+		controller.Step()
+		return spectator.Follow == 0
+	})
+
+	// the camera is reset when the followed Player leaves
+	assert.Nil(t, conn.Write(protocol.EncodeSpectatorFollow(spectator.ID, players[0].ID)))
+	await(t, controller, func() bool {
+		controller.Step()
+		return spectator.Follow == players[0].ID
+	})
+	first.Close(0, false)
+	await(t, controller, func() bool {
+		return controller.State().GetPlayerCount() == 1
+	})
+	assert.Equal(t, 0, spectator.Follow)
+}
+
+func TestSpectatorDisconnectsBeforeJoining(t *testing.T) {
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+
+	// the registration is posted to the game loop, the connection closes before it is applied
+	conn := transport.Dial(url.Values{roleParam: {roleSpectator}})
+	testutil.Eventually(t, func() bool {
+		return len(controller.events) == 1
+	})
+	conn.Close(0, false)
+	await(t, controller, func() bool {
+		return len(controller.events) == 0 && len(controller.State().GetSpectators()) == 0
+	})
+	assert.Empty(t, controller.State().GetSpectators())
+}
+
+func TestSpectatorDisconnectsBeforeFollowedPlayer(t *testing.T) {
+	controller, transport := newTestController(t, triebwerk.Config{Region: "test"}, auth.NewAnonymous(), storage.NewMemory(), testMasterServer{})
+	defer transport.Close()
+
+	first := join(t, transport, controller)
+	join(t, transport, controller)
+	conn, _ := spectate(t, transport, controller, controller.State().GetPlayers()[0].ID)
+
+	// the followed Player leaves first, the camera reset must not reach the closed spectator
+	first.Close(0, false)
+	testutil.Eventually(t, func() bool {
+		controller.disconnectedMutex.Lock()
+		defer controller.disconnectedMutex.Unlock()
+		return len(controller.disconnected) == 1
+	})
+	conn.Close(0, false)
+	testutil.Eventually(t, func() bool {
+		controller.disconnectedMutex.Lock()
+		defer controller.disconnectedMutex.Unlock()
+		return len(controller.disconnected) == 2
+	})
+	controller.ApplyEvents()
+	assert.Equal(t, 1, controller.State().GetPlayerCount())
+	assert.Empty(t, controller.State().GetSpectators())
+}
@@ -2,95 +2,290 @@ package infra
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	pb "github.com/awdng/triebwerk-proto/gameserver"
 	"github.com/awdng/triebwerk/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
-// MasterServerClient ...
+// MatchStatsMetadata is the gRPC metadata key of the stats of the players sent with EndGame,
+// the proto has no fields for them. Binary metadata is base64 encoded by gRPC
+const MatchStatsMetadata = "triebwerk-match-stats-bin"
+
+// RatingsMetadata is the gRPC metadata key of the ratings of the players sent with every heartbeat,
+// a JSON object of the ratings by global id
+const RatingsMetadata = "triebwerk-ratings-bin"
+
+// PlayerResult of a player in a finished match, sent as JSON list in the MatchStatsMetadata
+type PlayerResult struct {
+	Name        string  `json:"name"`
+	GlobalID    string  `json:"global_id"`
+	Rating      float64 `json:"rating"`
+	Score       int     `json:"score"`
+	Kills       int     `json:"kills"`
+	Deaths      int     `json:"deaths"`
+	Assists     int     `json:"assists"`
+	DamageDealt int     `json:"damage_dealt"`
+}
+
+// ServerSnapshot of a game for the master server. It is taken on the game loop, the calls to the
+// master server run in the background and never read the running game
+type ServerSnapshot struct {
+	Region      string
+	ElapsedTime uint32
+	Players     []PlayerResult
+}
+
+// ErrOffline is returned by calls that need a registered server while the master server is unreachable
+var ErrOffline = errors.New("infra: not registered with master server")
+
+const (
+	rpcTimeout = 1 * time.Second
+	// minBackoff and maxBackoff between two registration attempts
+	minBackoff = 1 * time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// MasterServerClient keeps the server registered with the master server. The server registers in the
+// background and registers again whenever the master server forgot it, the game keeps running meanwhile
 type MasterServerClient struct {
 	grpcClient pb.GameServerMasterClient
 	address    string
 	id         string
+
+	// initialized once Init was called, registrations start only afterwards
+	initialized bool
+	// registering is true while a registration loop runs
+	registering bool
+	// stopped on shutdown, no further calls are made
+	stopped    bool
+	stop       chan struct{}
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	mutex      sync.Mutex
 }
 
 // NewMasterServerClient ...
 func NewMasterServerClient(grpc pb.GameServerMasterClient) *MasterServerClient {
 	return &MasterServerClient{
 		grpcClient: grpc,
+		stop:       make(chan struct{}),
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
 	}
 }
 
-// Init ...
-func (m *MasterServerClient) Init(address string) {
+// Init registers the server with the master server in the background, retrying until it succeeds
+func (m *MasterServerClient) Init(address string) error {
+	m.mutex.Lock()
 	m.address = address
-	m.registerServer()
+	m.initialized = true
+	m.mutex.Unlock()
+	m.startRegistration()
+	return nil
+}
+
+// Online returns true while the server is registered with the master server
+func (m *MasterServerClient) Online() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.id != ""
+}
+
+// Stop heartbeats and registrations on shutdown. The master server has no call to deregister,
+// it drops the server once its heartbeats are missing
+func (m *MasterServerClient) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stopped {
+		return
+	}
+	m.stopped = true
+	close(m.stop)
+	log.Printf("MasterServer: Server %s stopped sending heartbeats", m.id)
+	m.id = ""
 }
 
 // GetServerState ...
 func (m *MasterServerClient) GetServerState() {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	id, ok := m.serverID()
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
 	defer cancel()
 	state, err := m.grpcClient.GetServerState(ctx, &pb.GetServerRequest{
-		Id: m.id,
+		Id: id,
 	})
 	if err != nil {
-		log.Println("Error Receiving ServerState - %v.ListFeatures(_) = _, %v", m.grpcClient, err)
+		log.Printf("MasterServer: Error Receiving ServerState: %v", err)
+		return
 	}
 	fmt.Println(state)
 }
 
+// startRegistration starts the registration loop unless it is running already
+func (m *MasterServerClient) startRegistration() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.registering || m.stopped || !m.initialized {
+		return
+	}
+	m.registering = true
+	go m.registrationLoop()
+}
+
+// registrationLoop registers the server, backing off exponentially after failures. The loop
+// ends with the same lock that sets the ID, an ID forgotten afterwards starts a new loop
+func (m *MasterServerClient) registrationLoop() {
+	backoff := m.minBackoff
+	for attempt := 1; ; attempt++ {
+		err := m.registerServer()
+		if err == nil {
+			return
+		}
+		log.Printf("MasterServer: Registration attempt %d failed, retrying in %s: %v", attempt, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-m.stop:
+			m.mutex.Lock()
+			m.registering = false
+			m.mutex.Unlock()
+			return
+		}
+		backoff *= 2
+		if backoff > m.maxBackoff {
+			backoff = m.maxBackoff
+		}
+	}
+}
+
 // RegisterServer ...
-func (m *MasterServerClient) registerServer() {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+func (m *MasterServerClient) registerServer() error {
+	m.mutex.Lock()
+	address := m.address
+	m.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
 	defer cancel()
 	server, err := m.grpcClient.RegisterServer(ctx, &pb.ServerRegisterRequest{
-		Address: m.address,
+		Address: address,
 	})
 	if err != nil {
-		log.Println("Error Registering Server - %v.ListFeatures(_) = _, %v", m.grpcClient, err)
+		return fmt.Errorf("registering server: %w", err)
+	}
+	if server.GetId() == "" {
+		return errors.New("registering server: master server returned no ID")
 	}
-	m.id = server.Id
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.registering = false
+	if m.stopped {
+		return nil
+	}
+	m.id = server.GetId()
+	log.Printf("MasterServer: Server registered with global ID %s", m.id)
+	return nil
+}
+
+// serverID returns the ID of the registered server, false while offline
+func (m *MasterServerClient) serverID() (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.id, m.id != ""
+}
+
+// offline makes sure a registration runs while the server has no ID
+func (m *MasterServerClient) offline() error {
+	m.startRegistration()
+	return ErrOffline
+}
+
+// forget the ID the master server does not know anymore and register again
+func (m *MasterServerClient) forget(id string) {
+	m.mutex.Lock()
+	if m.id == id {
+		m.id = ""
+	}
+	m.mutex.Unlock()
+	log.Printf("MasterServer: Master server does not know server %s anymore, registering again", id)
+	m.startRegistration()
 }
 
 // SendHeartbeat ...
-func (m *MasterServerClient) SendHeartbeat(gameState *model.GameState) {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+func (m *MasterServerClient) SendHeartbeat(snapshot *ServerSnapshot) error {
+	id, ok := m.serverID()
+	if !ok {
+		return m.offline()
+	}
+	ratings := make(map[string]float64, len(snapshot.Players))
+	for _, p := range snapshot.Players {
+		ratings[p.GlobalID] = p.Rating
+	}
+	encoded, err := json.Marshal(ratings)
+	if err != nil {
+		return fmt.Errorf("sending heartbeat: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
 	defer cancel()
-	_, err := m.grpcClient.SendHeartbeat(ctx, &pb.ServerStateRequest{
-		State: m.buildServerState(gameState),
+	ctx = metadata.AppendToOutgoingContext(ctx, RatingsMetadata, string(encoded))
+	_, err = m.grpcClient.SendHeartbeat(ctx, &pb.ServerStateRequest{
+		State: m.buildServerState(id, snapshot),
 	})
+	if status.Code(err) == codes.NotFound {
+		m.forget(id)
+	}
 	if err != nil {
-		log.Println("Error Sending ServerState - %v.ListFeatures(_) = _, %v", m.grpcClient, err)
+		return fmt.Errorf("sending heartbeat: %w", err)
 	}
+	return nil
 }
 
 // EndGame ...
-func (m *MasterServerClient) EndGame(gameState *model.GameState) {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+func (m *MasterServerClient) EndGame(snapshot *ServerSnapshot) error {
+	id, ok := m.serverID()
+	if !ok {
+		return m.offline()
+	}
+	results, err := json.Marshal(snapshot.Players)
+	if err != nil {
+		return fmt.Errorf("ending game: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
 	defer cancel()
-	_, err := m.grpcClient.EndGame(ctx, &pb.EndGameRequest{
-		State: m.buildServerState(gameState),
+	ctx = metadata.AppendToOutgoingContext(ctx, MatchStatsMetadata, string(results))
+	_, err = m.grpcClient.EndGame(ctx, &pb.EndGameRequest{
+		State: m.buildServerState(id, snapshot),
 	})
+	if status.Code(err) == codes.NotFound {
+		m.forget(id)
+	}
 	if err != nil {
-		log.Println("Error When Ending Game - %v.ListFeatures(_) = _, %v", m.grpcClient, err)
+		return fmt.Errorf("ending game: %w", err)
 	}
+	return nil
 }
 
 // AuthorizePlayer ...
 func (m *MasterServerClient) AuthorizePlayer(token string, player *model.Player) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	id, _ := m.serverID()
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
 	defer cancel()
 	authResp, err := m.grpcClient.AuthorizePlayer(ctx, &pb.AuthorizePlayerRequest{
 		Token: token,
+		Id:    id,
 	})
 	if err != nil {
-		log.Println("Error authorizing player - %v.ListFeatures(_) = _, %v", m.grpcClient, err)
-		// todo wrap error
-		return err
+		return fmt.Errorf("authorizing player: %w", err)
 	}
 	player.GlobalID = authResp.GetGlobalId()
 	player.Nickname = authResp.GetName()
@@ -98,25 +293,53 @@ func (m *MasterServerClient) AuthorizePlayer(token string, player *model.Player)
 	return nil
 }
 
-func (m *MasterServerClient) buildServerState(gameState *model.GameState) *pb.ServerState {
-	statePlayers := gameState.GetPlayers()
+// Snapshot of the players and the time of a game
+func Snapshot(gameState *model.GameState) *ServerSnapshot {
+	snapshot := &ServerSnapshot{
+		Region:      gameState.Region,
+		ElapsedTime: gameState.GameTime(),
+		Players:     []PlayerResult{},
+	}
+	for _, p := range gameState.GetPlayers() {
+		// bots and anonymous players have no global id to be tracked by
+		if p.Bot || p.GlobalID == "" {
+			continue
+		}
+		snapshot.Players = append(snapshot.Players, PlayerResult{
+			Name:        p.Nickname,
+			GlobalID:    p.GlobalID,
+			Rating:      p.Rating,
+			Score:       p.Score,
+			Kills:       p.Stats.Kills,
+			Deaths:      p.Stats.Deaths,
+			Assists:     p.Stats.Assists,
+			DamageDealt: p.Stats.DamageDealt,
+		})
+	}
+	return snapshot
+}
 
+func (m *MasterServerClient) buildServerState(id string, snapshot *ServerSnapshot) *pb.ServerState {
+	// the proto has no field for ratings, they are sent as RatingsMetadata
 	players := []*pb.Player{}
-	for _, pd := range statePlayers {
+	for _, pd := range snapshot.Players {
 		p := &pb.Player{
-			Name:  pd.Nickname,
+			Name:  pd.Name,
 			Score: int32(pd.Score),
 			Team:  0,
 		}
 		players = append(players, p)
 	}
 
+	m.mutex.Lock()
+	address := m.address
+	m.mutex.Unlock()
 	return &pb.ServerState{
-		Region:      gameState.Region,
-		Id:          m.id,
-		Address:     m.address,
+		Region:      snapshot.Region,
+		Id:          id,
+		Address:     address,
 		UpdatedAt:   int32(time.Now().UTC().Unix()),
-		ElapsedTime: int32(gameState.GameTime()),
+		ElapsedTime: int32(snapshot.ElapsedTime),
 		Players:     players,
 	}
 }
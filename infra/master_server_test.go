@@ -0,0 +1,127 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/awdng/triebwerk-proto/gameserver"
+	"github.com/awdng/triebwerk/internal/testutil"
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyMaster fails a number of registrations and knows only the servers it registered
+type flakyMaster struct {
+	pb.GameServerMasterClient
+	failures      int
+	registrations int
+	known         map[string]bool
+	mutex         sync.Mutex
+}
+
+func (f *flakyMaster) RegisterServer(ctx context.Context, in *pb.ServerRegisterRequest, opts ...grpc.CallOption) (*pb.ServerRegisterResponse, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return nil, status.Error(codes.Unavailable, "master server unavailable")
+	}
+	f.registrations++
+	id := string(rune('a' + f.registrations))
+	f.known[id] = true
+	return &pb.ServerRegisterResponse{Id: id}, nil
+}
+
+func (f *flakyMaster) SendHeartbeat(ctx context.Context, in *pb.ServerStateRequest, opts ...grpc.CallOption) (*pb.ServerState, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if !f.known[in.GetState().GetId()] {
+		return nil, status.Error(codes.NotFound, "unknown server")
+	}
+	return in.GetState(), nil
+}
+
+func (f *flakyMaster) forgetAll() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.known = map[string]bool{}
+}
+
+func (f *flakyMaster) count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.registrations
+}
+
+func newTestClient(master pb.GameServerMasterClient) *MasterServerClient {
+	m := NewMasterServerClient(master)
+	m.minBackoff = time.Millisecond
+	m.maxBackoff = 4 * time.Millisecond
+	return m
+}
+
+func TestRegistrationRetries(t *testing.T) {
+	master := &flakyMaster{failures: 3, known: map[string]bool{}}
+	m := newTestClient(master)
+	defer m.Stop()
+	state := Snapshot(model.NewGameState("test"))
+
+	assert.Nil(t, m.Init("localhost:80"))
+	assert.Equal(t, ErrOffline, m.SendHeartbeat(state))
+	testutil.Eventually(t, m.Online)
+	assert.Equal(t, 1, master.count())
+	assert.Nil(t, m.SendHeartbeat(state))
+}
+
+func TestReregistrationOfUnknownServer(t *testing.T) {
+	master := &flakyMaster{known: map[string]bool{}}
+	m := newTestClient(master)
+	defer m.Stop()
+	state := Snapshot(model.NewGameState("test"))
+	assert.Nil(t, m.Init("localhost:80"))
+	testutil.Eventually(t, m.Online)
+
+	master.forgetAll()
+	assert.NotNil(t, m.SendHeartbeat(state))
+	testutil.Eventually(t, func() bool {
+		return master.count() == 2 && m.Online()
+	})
+	assert.Nil(t, m.SendHeartbeat(state))
+}
+
+func TestOfflineCallsRegisterAgain(t *testing.T) {
+	master := &flakyMaster{known: map[string]bool{}}
+	m := newTestClient(master)
+	defer m.Stop()
+	state := Snapshot(model.NewGameState("test"))
+	assert.Equal(t, ErrOffline, m.SendHeartbeat(state))
+	assert.Equal(t, 0, master.count())
+
+	assert.Nil(t, m.Init("localhost:80"))
+	testutil.Eventually(t, m.Online)
+	// the ID was forgotten after the registration loop ended
+	m.mutex.Lock()
+	m.id = ""
+	m.mutex.Unlock()
+	assert.Equal(t, ErrOffline, m.SendHeartbeat(state))
+	testutil.Eventually(t, func() bool {
+		return master.count() == 2 && m.Online()
+	})
+	assert.Nil(t, m.SendHeartbeat(state))
+}
+
+func TestStop(t *testing.T) {
+	master := &flakyMaster{failures: 1000, known: map[string]bool{}}
+	m := newTestClient(master)
+	assert.Nil(t, m.Init("localhost:80"))
+	m.Stop()
+	m.Stop()
+	assert.False(t, m.Online())
+	assert.True(t, errors.Is(m.EndGame(Snapshot(model.NewGameState("test"))), ErrOffline))
+}
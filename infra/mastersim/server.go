@@ -0,0 +1,254 @@
+package mastersim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/awdng/triebwerk-proto/gameserver"
+	"github.com/awdng/triebwerk/infra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Account a token authorizes as
+type Account struct {
+	GlobalID string
+	Name     string
+}
+
+// Server is an in-memory stand-in for the master server, for local development and tests.
+// Servers that miss their heartbeats for the expiry are dropped from the server list
+type Server struct {
+	pb.UnimplementedGameServerMasterServer
+
+	// tokens that are authorized, every token is authorized as a player named like it if empty
+	tokens  map[string]Account
+	expiry  time.Duration
+	servers map[string]*pb.ServerState
+	// ratings of the players on each server by global id, from the last heartbeat
+	ratings map[string]map[string]float64
+	ended   []*pb.ServerState
+	results [][]infra.PlayerResult
+	nextID  int
+	mutex   sync.Mutex
+}
+
+// NewServer ...
+func NewServer(tokens map[string]Account, expiry time.Duration) *Server {
+	return &Server{
+		tokens:  tokens,
+		expiry:  expiry,
+		servers: make(map[string]*pb.ServerState),
+		ratings: make(map[string]map[string]float64),
+	}
+}
+
+// ParseTokens from a comma separated list of token:name or token:name:globalID entries
+func ParseTokens(spec string) (map[string]Account, error) {
+	tokens := make(map[string]Account)
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("mastersim: invalid token %q, expected token:name[:globalID]", entry)
+		}
+		account := Account{GlobalID: "sim-" + parts[0], Name: parts[1]}
+		if len(parts) == 3 {
+			account.GlobalID = parts[2]
+		}
+		tokens[parts[0]] = account
+	}
+	return tokens, nil
+}
+
+// RegisterServer ...
+func (s *Server) RegisterServer(ctx context.Context, req *pb.ServerRegisterRequest) (*pb.ServerRegisterResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextID++
+	id := fmt.Sprintf("server-%d", s.nextID)
+	s.servers[id] = &pb.ServerState{
+		Id:        id,
+		Address:   req.GetAddress(),
+		UpdatedAt: int32(time.Now().Unix()),
+	}
+	log.Printf("MasterSim: Server %s registered with address %s", id, req.GetAddress())
+	return &pb.ServerRegisterResponse{Id: id}, nil
+}
+
+// SendHeartbeat ...
+func (s *Server) SendHeartbeat(ctx context.Context, req *pb.ServerStateRequest) (*pb.ServerState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state, err := s.server(req.GetState().GetId())
+	if err != nil {
+		return nil, err
+	}
+	s.update(state, req.GetState())
+	s.ratings[state.Id] = playerRatings(ctx)
+	return state, nil
+}
+
+// GetServerState ...
+func (s *Server) GetServerState(ctx context.Context, req *pb.GetServerRequest) (*pb.ServerState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.server(req.GetId())
+}
+
+// GetServerList of a region, all regions if none is requested
+func (s *Server) GetServerList(ctx context.Context, req *pb.GetServerListRequest) (*pb.ServerList, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.expire()
+	list := &pb.ServerList{}
+	for _, state := range s.servers {
+		if req.GetRegion() == "" || req.GetRegion() == state.Region {
+			list.Servers = append(list.Servers, state)
+		}
+	}
+	sort.Slice(list.Servers, func(i, j int) bool {
+		return list.Servers[i].Id < list.Servers[j].Id
+	})
+	return list, nil
+}
+
+// EndGame keeps the final state of a game
+func (s *Server) EndGame(ctx context.Context, req *pb.EndGameRequest) (*pb.EndGameResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state, err := s.server(req.GetState().GetId())
+	if err != nil {
+		return nil, err
+	}
+	s.update(state, req.GetState())
+	results := matchResults(ctx)
+	s.ended = append(s.ended, req.GetState())
+	s.results = append(s.results, results)
+	log.Printf("MasterSim: Game on server %s ended with %d players", state.Id, len(req.GetState().GetPlayers()))
+	for _, r := range results {
+		log.Printf("MasterSim:   %-20s %d (%d kills, %d deaths, %d assists, %d damage)", r.Name, r.Score, r.Kills, r.Deaths, r.Assists, r.DamageDealt)
+	}
+	return &pb.EndGameResponse{}, nil
+}
+
+// matchResults sent along with EndGame, empty if the game server sent none
+func matchResults(ctx context.Context) []infra.PlayerResult {
+	results := []infra.PlayerResult{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(infra.MatchStatsMetadata) {
+		if err := json.Unmarshal([]byte(value), &results); err != nil {
+			log.Printf("MasterSim: Invalid match stats: %v", err)
+		}
+	}
+	return results
+}
+
+// playerRatings sent along with a heartbeat, empty if the game server sent none
+func playerRatings(ctx context.Context) map[string]float64 {
+	ratings := make(map[string]float64)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(infra.RatingsMetadata) {
+		if err := json.Unmarshal([]byte(value), &ratings); err != nil {
+			log.Printf("MasterSim: Invalid ratings: %v", err)
+		}
+	}
+	return ratings
+}
+
+// AuthorizePlayer with one of the configured tokens
+func (s *Server) AuthorizePlayer(ctx context.Context, req *pb.AuthorizePlayerRequest) (*pb.AuthorizePlayerResponse, error) {
+	token := req.GetToken()
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "empty token")
+	}
+	account, ok := Account{GlobalID: "sim-" + token, Name: token}, true
+	if len(s.tokens) > 0 {
+		account, ok = s.tokens[token]
+	}
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unknown token")
+	}
+	return &pb.AuthorizePlayerResponse{
+		Authorized: true,
+		Id:         req.GetId(),
+		GlobalId:   account.GlobalID,
+		Name:       account.Name,
+	}, nil
+}
+
+// Ratings of the players on a server by global id, as sent with its last heartbeat
+func (s *Server) Ratings(id string) map[string]float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ratings := make(map[string]float64, len(s.ratings[id]))
+	for globalID, rating := range s.ratings[id] {
+		ratings[globalID] = rating
+	}
+	return ratings
+}
+
+// EndedGames returns the final states of all ended games
+func (s *Server) EndedGames() []*pb.ServerState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ended := make([]*pb.ServerState, len(s.ended))
+	copy(ended, s.ended)
+	return ended
+}
+
+// EndedResults returns the player results of all ended games, in the order of EndedGames
+func (s *Server) EndedResults() [][]infra.PlayerResult {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	results := make([][]infra.PlayerResult, len(s.results))
+	copy(results, s.results)
+	return results
+}
+
+// Forget all servers, like a restarted master server
+func (s *Server) Forget() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.servers = make(map[string]*pb.ServerState)
+	s.ratings = make(map[string]map[string]float64)
+}
+
+func (s *Server) server(id string) (*pb.ServerState, error) {
+	state, ok := s.servers[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown server %s", id)
+	}
+	return state, nil
+}
+
+func (s *Server) update(state *pb.ServerState, reported *pb.ServerState) {
+	state.Region = reported.GetRegion()
+	state.ElapsedTime = reported.GetElapsedTime()
+	state.Players = reported.GetPlayers()
+	state.UpdatedAt = int32(time.Now().Unix())
+}
+
+// expire servers that missed their heartbeats
+func (s *Server) expire() {
+	if s.expiry <= 0 {
+		return
+	}
+	deadline := time.Now().Add(-s.expiry).Unix()
+	for id, state := range s.servers {
+		if int64(state.UpdatedAt) < deadline {
+			delete(s.servers, id)
+			delete(s.ratings, id)
+			log.Printf("MasterSim: Server %s expired", id)
+		}
+	}
+}
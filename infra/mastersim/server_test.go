@@ -0,0 +1,95 @@
+package mastersim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/awdng/triebwerk-proto/gameserver"
+	"github.com/awdng/triebwerk/infra"
+	"github.com/awdng/triebwerk/internal/testutil"
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial a Server served in memory
+func dial(t *testing.T, server *Server) (pb.GameServerMasterClient, func()) {
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	pb.RegisterGameServerMasterServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	assert.Nil(t, err)
+	return pb.NewGameServerMasterClient(conn), func() {
+		conn.Close()
+		grpcServer.Stop()
+	}
+}
+
+func TestParseTokens(t *testing.T) {
+	tokens, err := ParseTokens("alice:Alice, bob:Bob:global-bob")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]Account{
+		"alice": {GlobalID: "sim-alice", Name: "Alice"},
+		"bob":   {GlobalID: "global-bob", Name: "Bob"},
+	}, tokens)
+
+	_, err = ParseTokens("alice")
+	assert.NotNil(t, err)
+}
+
+func TestGameServerLifecycle(t *testing.T) {
+	server := NewServer(map[string]Account{"secret": {GlobalID: "g-1", Name: "Alice"}}, time.Minute)
+	grpcClient, stop := dial(t, server)
+	defer stop()
+	client := infra.NewMasterServerClient(grpcClient)
+	defer client.Stop()
+
+	assert.Nil(t, client.Init("localhost:9090"))
+	testutil.Eventually(t, client.Online)
+
+	player := model.NewPlayer(1, 0, 0, nil)
+	assert.Nil(t, client.AuthorizePlayer("secret", player))
+	assert.Equal(t, "g-1", player.GlobalID)
+	assert.Equal(t, "Alice", player.Nickname)
+	assert.NotNil(t, client.AuthorizePlayer("guess", model.NewPlayer(2, 0, 0, nil)))
+
+	game := model.NewGameState("EU")
+	game.AddPlayer(player)
+	player.Rating = 1600
+	// bots and players without a global id are not reported
+	bot := model.NewPlayer(3, 0, 0, nil)
+	bot.Bot = true
+	game.AddPlayer(bot)
+	game.AddPlayer(model.NewPlayer(4, 0, 0, nil))
+	state := infra.Snapshot(game)
+	assert.Nil(t, client.SendHeartbeat(state))
+	list, err := grpcClient.GetServerList(context.Background(), &pb.GetServerListRequest{Region: "EU"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list.GetServers()))
+	assert.Equal(t, "localhost:9090", list.GetServers()[0].GetAddress())
+	assert.Equal(t, 1, len(list.GetServers()[0].GetPlayers()))
+	assert.Equal(t, "Alice", list.GetServers()[0].GetPlayers()[0].GetName())
+	assert.Equal(t, map[string]float64{"g-1": 1600}, server.Ratings(list.GetServers()[0].GetId()))
+
+	// a restarted master server does not know the game server anymore
+	server.Forget()
+	assert.NotNil(t, client.SendHeartbeat(state))
+	testutil.Eventually(t, func() bool {
+		return client.SendHeartbeat(state) == nil
+	})
+
+	player.Score = 2
+	player.Stats = model.MatchStats{Kills: 2, Deaths: 1, Assists: 3, DamageDealt: 175}
+	assert.Nil(t, client.EndGame(infra.Snapshot(game)))
+	assert.Equal(t, 1, len(server.EndedGames()))
+	assert.Equal(t, [][]infra.PlayerResult{{
+		{Name: "Alice", GlobalID: "g-1", Rating: 1600, Score: 2, Kills: 2, Deaths: 1, Assists: 3, DamageDealt: 175},
+	}}, server.EndedResults())
+}
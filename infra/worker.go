@@ -0,0 +1,119 @@
+package infra
+
+import (
+	"errors"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultQueueSize of a Worker if none is configured
+	DefaultQueueSize = 64
+	// DefaultConcurrency of a Worker if none is configured
+	DefaultConcurrency = 4
+)
+
+// ErrQueueFull is returned if a job is submitted while the queue is full
+var ErrQueueFull = errors.New("infra: queue full")
+
+// ErrStopped is returned if a job is submitted after the Worker stopped
+var ErrStopped = errors.New("infra: worker stopped")
+
+// metrics of all workers, published at /debug/vars of the admin listener:
+// queue_depth, and calls, errors, dropped and latency_ms (total) per job name
+var metrics = expvar.NewMap("infra")
+
+// Job is a blocking call to an external service
+type Job struct {
+	// Name groups the metrics of jobs, eg. the RPC called
+	Name string
+	Run  func() error
+	// Done is called with the result on the worker goroutine, optional
+	Done func(err error)
+}
+
+// Worker runs jobs from a bounded queue in the background,
+// so slow calls to external services never block the caller
+type Worker struct {
+	jobs    chan Job
+	stop    chan struct{}
+	stopped sync.Once
+	wg      sync.WaitGroup
+	// mutex guards submitting against stopping
+	mutex sync.RWMutex
+}
+
+// NewWorker starts a Worker running up to concurrency jobs at a time
+func NewWorker(queueSize, concurrency int) *Worker {
+	if queueSize < 1 {
+		queueSize = DefaultQueueSize
+	}
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+	w := &Worker{
+		jobs: make(chan Job, queueSize),
+		stop: make(chan struct{}),
+	}
+	w.wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go w.run()
+	}
+	return w
+}
+
+// Submit a job without blocking, fails if the queue is full
+func (w *Worker) Submit(job Job) error {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	select {
+	case <-w.stop:
+		return ErrStopped
+	default:
+	}
+
+	select {
+	case w.jobs <- job:
+		metrics.Add("queue_depth", 1)
+		return nil
+	default:
+		metrics.Add(job.Name+".dropped", 1)
+		return ErrQueueFull
+	}
+}
+
+// QueueDepth is the number of jobs waiting
+func (w *Worker) QueueDepth() int {
+	return len(w.jobs)
+}
+
+// Stop the Worker after all queued jobs are done
+func (w *Worker) Stop() {
+	w.stopped.Do(func() {
+		w.mutex.Lock()
+		close(w.stop)
+		close(w.jobs)
+		w.mutex.Unlock()
+	})
+	w.wg.Wait()
+}
+
+func (w *Worker) run() {
+	defer w.wg.Done()
+	for job := range w.jobs {
+		metrics.Add("queue_depth", -1)
+		start := time.Now()
+		err := job.Run()
+		metrics.Add(job.Name+".latency_ms", int64(time.Since(start)/time.Millisecond))
+		metrics.Add(job.Name+".calls", 1)
+		if err != nil {
+			metrics.Add(job.Name+".errors", 1)
+			log.Printf("Worker: Job %s failed after %s: %s", job.Name, time.Since(start), err)
+		}
+		if job.Done != nil {
+			job.Done(err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package infra
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerRunsJobs(t *testing.T) {
+	w := NewWorker(4, 2)
+	results := make(chan error, 2)
+	failure := errors.New("unavailable")
+
+	assert.Nil(t, w.Submit(Job{Name: "test", Run: func() error { return nil }, Done: func(err error) { results <- err }}))
+	assert.Nil(t, w.Submit(Job{Name: "test", Run: func() error { return failure }, Done: func(err error) { results <- err }}))
+	errs := []error{<-results, <-results}
+	assert.Contains(t, errs, nil)
+	assert.Contains(t, errs, failure)
+
+	w.Stop()
+	assert.Equal(t, ErrStopped, w.Submit(Job{Name: "test", Run: func() error { return nil }}))
+}
+
+func TestWorkerQueueFull(t *testing.T) {
+	w := NewWorker(1, 1)
+	running := make(chan struct{})
+	release := make(chan struct{})
+	block := func() error {
+		running <- struct{}{}
+		<-release
+		return nil
+	}
+
+	assert.Nil(t, w.Submit(Job{Name: "test", Run: block}))
+	<-running // the first job blocks the only worker goroutine
+	assert.Nil(t, w.Submit(Job{Name: "test", Run: func() error { return nil }}))
+	assert.Equal(t, 1, w.QueueDepth())
+	assert.Equal(t, ErrQueueFull, w.Submit(Job{Name: "test", Run: func() error { return nil }}))
+
+	close(release)
+	w.Stop()
+	assert.Equal(t, 0, w.QueueDepth())
+}
@@ -0,0 +1,25 @@
+// Package testutil holds helpers shared by the tests of several packages
+package testutil
+
+import (
+	"testing"
+	"time"
+)
+
+// timeout of Eventually
+const timeout = 2 * time.Second
+
+// Eventually polls the condition until it is met, the test fails if it is not met within the timeout.
+// The condition runs on the calling goroutine. assert.Eventually of testify v1.4.0 runs it on a new
+// goroutine every tick, concurrently with the previous runs, and closes the result channel on return
+// while a slow run may still send to it
+func Eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition never satisfied")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock the game reads the time from
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock
+type SystemClock struct{}
+
+// Now ...
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock only moves when advanced, for reproducible simulations
+type ManualClock struct {
+	now   time.Time
+	mutex sync.RWMutex
+}
+
+// NewManualClock creates a ManualClock starting at start
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now ...
+func (c *ManualClock) Now() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.now
+}
+
+// Advance the clock by d
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
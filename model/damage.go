@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// WeaponCannon is the name of the tank cannon in the damage ledger and the kill feed
+const WeaponCannon = "cannon"
+
+// cannonDamage a projectile of the cannon deals
+const cannonDamage = 25
+
+// Damage a Player took from an attacker
+type Damage struct {
+	Attacker int
+	Amount   int
+	Weapon   string
+	At       time.Time
+}
+
+// DamageLedger records the damage a Player took during its current life
+type DamageLedger struct {
+	entries []Damage
+}
+
+// Add damage to the ledger
+func (l *DamageLedger) Add(damage Damage) {
+	l.entries = append(l.entries, damage)
+}
+
+// Entries of the ledger, oldest first
+func (l *DamageLedger) Entries() []Damage {
+	return l.entries
+}
+
+// Assists of a kill are all attackers besides the killer, in the order they first dealt damage
+func (l *DamageLedger) Assists(killer int) []int {
+	assists := []int{}
+	seen := map[int]bool{killer: true}
+	for _, d := range l.entries {
+		if !seen[d.Attacker] {
+			seen[d.Attacker] = true
+			assists = append(assists, d.Attacker)
+		}
+	}
+	return assists
+}
+
+// Clear the ledger for a new life
+func (l *DamageLedger) Clear() {
+	l.entries = nil
+}
+
+// Kill of a Player, reported in the kill feed
+type Kill struct {
+	Killer  int
+	Victim  int
+	Assists []int
+	Weapon  string
+	At      time.Time
+}
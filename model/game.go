@@ -1,6 +1,8 @@
 package model
 
 import (
+	"math/rand"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,8 +19,14 @@ type GameState struct {
 	playerID    int64
 	playerCount int
 	players     map[int]*Player
+	spectators  map[int]*Spectator
 	Map         *Map
 	mutex       *sync.RWMutex
+
+	// clock and rng are injectable so simulations can be reproduced
+	clock    Clock
+	rng      *rand.Rand
+	rngMutex sync.Mutex
 }
 
 // NewGameState ...
@@ -28,11 +36,45 @@ func NewGameState(region string) *GameState {
 		inProgress: false,
 		length:     time.Minute * gameLength,
 		players:    make(map[int]*Player),
+		spectators: make(map[int]*Spectator),
 		Map:        NewMap(),
 		mutex:      &sync.RWMutex{},
+		clock:      SystemClock{},
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
+// SetClock the game reads its time from
+func (g *GameState) SetClock(clock Clock) {
+	g.clock = clock
+}
+
+// Clock of the game
+func (g *GameState) Clock() Clock {
+	return g.clock
+}
+
+// Seed the random number generator of the game, e.g. for spawn points
+func (g *GameState) Seed(seed int64) {
+	g.rngMutex.Lock()
+	defer g.rngMutex.Unlock()
+	g.rng = rand.New(rand.NewSource(seed))
+}
+
+// Intn returns a random number in [0,n) from the rng of the game
+func (g *GameState) Intn(n int) int {
+	g.rngMutex.Lock()
+	defer g.rngMutex.Unlock()
+	return g.rng.Intn(n)
+}
+
+// GetRandomSpawn of the Map that is not occupied by any of the Players
+func (g *GameState) GetRandomSpawn(players []*Player) *Point {
+	g.rngMutex.Lock()
+	defer g.rngMutex.Unlock()
+	return g.Map.GetRandomSpawn(g.rng, players)
+}
+
 // ReadyToStart ...
 func (g *GameState) ReadyToStart() bool {
 	return g.GetPlayerCount() >= 1 && !g.InProgress()
@@ -43,12 +85,9 @@ func (g *GameState) Start() {
 	players := g.GetPlayers()
 	// randomize player spawns
 	for _, p := range players {
-		p.Health = 100
-		p.Score = 0
-		spawn := g.Map.GetRandomSpawn(players)
-		p.Collider.ChangePosition(spawn.X, spawn.Y)
+		p.Reset(g.GetRandomSpawn(players))
 	}
-	g.startTime = time.Now()
+	g.startTime = g.clock.Now()
 	g.mutex.Lock()
 	defer g.mutex.Unlock()
 	g.inProgress = true
@@ -70,15 +109,20 @@ func (g *GameState) InProgress() bool {
 
 // HasEnded ...
 func (g *GameState) HasEnded() bool {
-	return time.Now().Sub(g.startTime) >= g.length
+	return g.clock.Now().Sub(g.startTime) >= g.length
+}
+
+// Length of a game
+func (g *GameState) Length() time.Duration {
+	return g.length
 }
 
 // GameTime returns the current game time since start in milliseconds
 func (g *GameState) GameTime() uint32 {
-	return uint32(time.Now().Sub(g.startTime) / time.Millisecond)
+	return uint32(g.clock.Now().Sub(g.startTime) / time.Millisecond)
 }
 
-// GetPlayers returns the PlayerList
+// GetPlayers returns the PlayerList ordered by ID
 func (g *GameState) GetPlayers() []*Player {
 	players := make([]*Player, 0)
 	g.mutex.RLock()
@@ -87,9 +131,43 @@ func (g *GameState) GetPlayers() []*Player {
 	for _, p := range g.players {
 		players = append(players, p)
 	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].ID < players[j].ID
+	})
 	return players
 }
 
+// GetSpectators returns the SpectatorList ordered by ID
+func (g *GameState) GetSpectators() []*Spectator {
+	spectators := make([]*Spectator, 0)
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	for _, s := range g.spectators {
+		spectators = append(spectators, s)
+	}
+	sort.Slice(spectators, func(i, j int) bool {
+		return spectators[i].ID < spectators[j].ID
+	})
+	return spectators
+}
+
+// AddSpectator to the game, spectators do not count as players
+func (g *GameState) AddSpectator(spectator *Spectator) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.spectators[spectator.ID] = spectator
+}
+
+// RemoveSpectator from the game
+func (g *GameState) RemoveSpectator(spectator *Spectator) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	delete(g.spectators, spectator.ID)
+}
+
 // GetPlayerCount ...
 func (g *GameState) GetPlayerCount() int {
 	g.mutex.RLock()
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPlayersOrderedByID(t *testing.T) {
+	game := NewGameState("test")
+	for _, id := range []int{5, 2, 9, 1, 7} {
+		game.AddPlayer(NewPlayer(id, 0, 0, nil))
+	}
+
+	ids := []int{}
+	for _, p := range game.GetPlayers() {
+		ids = append(ids, p.ID)
+	}
+	assert.Equal(t, []int{1, 2, 5, 7, 9}, ids)
+}
+
+func TestGameTimeFollowsClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(1500000000, 0))
+	game := NewGameState("test")
+	game.SetClock(clock)
+	game.Start()
+
+	assert.Equal(t, uint32(0), game.GameTime())
+	clock.Advance(1500 * time.Millisecond)
+	assert.Equal(t, uint32(1500), game.GameTime())
+	assert.False(t, game.HasEnded())
+	clock.Advance(game.Length())
+	assert.True(t, game.HasEnded())
+}
+
+func TestSeededGamesAreReproducible(t *testing.T) {
+	simulate := func() []Point {
+		game := NewGameState("test")
+		game.SetClock(NewManualClock(time.Unix(1500000000, 0)))
+		game.Seed(42)
+		for id := 1; id <= 4; id++ {
+			game.AddPlayer(NewPlayer(id, 0, 0, nil))
+		}
+		game.Start()
+
+		for tick := 0; tick < 100; tick++ {
+			players := game.GetPlayers()
+			for _, p := range players {
+				p.Control = Controls{Forward: true, Left: (tick/10+p.ID)%2 == 0, Shoot: tick%7 == 0}
+				p.Update(players, game, 0.033)
+				p.HandleRespawn(game)
+			}
+		}
+
+		positions := []Point{}
+		for _, p := range game.GetPlayers() {
+			positions = append(positions, *p.Collider.Pivot)
+		}
+		return positions
+	}
+
+	assert.Equal(t, simulate(), simulate())
+}
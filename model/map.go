@@ -3,7 +3,6 @@ package model
 import (
 	"encoding/json"
 	"math/rand"
-	"time"
 )
 
 const colliderConfig = string(`[
@@ -914,6 +913,7 @@ func (c *Collider) getPolygon() Polygon {
 
 // Map represents a game map
 type Map struct {
+	ID       string
 	Collider []*Collider
 	Spawns   []*Point
 }
@@ -924,6 +924,7 @@ func NewMap() *Map {
 	json.Unmarshal([]byte(colliderConfig), &colliders)
 
 	return &Map{
+		ID: "default",
 		Spawns: []*Point{
 			&Point{
 				X: 33.92122716470902,
@@ -994,20 +995,45 @@ func NewMap() *Map {
 	}
 }
 
-// GetRandomSpawn Point
-func (m *Map) GetRandomSpawn(players []*Player) *Point {
-	rand.Seed(time.Now().Unix())
-	index := rand.Intn(len(m.Spawns))
-	spawn := m.Spawns[index]
-	occupied := false
-	for _, p := range players {
-		if p.IsAlive() && spawn.WithinDistanceOf(4, p.Collider.Pivot) {
-			occupied = true
-			break
+// GetRandomSpawn Point that is not occupied by an alive Player. If all spawns are occupied
+// the one with the fewest Players nearby is used
+func (m *Map) GetRandomSpawn(rng *rand.Rand, players []*Player) *Point {
+	var best *Point
+	fewest := -1
+	for _, i := range rng.Perm(len(m.Spawns)) {
+		spawn := m.Spawns[i]
+		occupants := 0
+		for _, p := range players {
+			if p.IsAlive() && spawn.WithinDistanceOf(4, p.Collider.Pivot) {
+				occupants++
+			}
+		}
+		if occupants == 0 {
+			return spawn
+		}
+		if fewest < 0 || occupants < fewest {
+			best, fewest = spawn, occupants
 		}
 	}
-	if occupied { // try again
-		return m.GetRandomSpawn(players)
+	return best
+}
+
+// LineOfSight returns true if a projectile could fly from one Point to the other without hitting the environment
+func (m *Map) LineOfSight(from *Point, to *Point) bool {
+	for _, collider := range m.Collider {
+		if !collider.Projectile { // projectiles fly across this collider
+			continue
+		}
+		if from.IsInPolygon(collider.Points) || to.IsInPolygon(collider.Points) {
+			return false
+		}
+		for i := range collider.Points {
+			a := collider.Points[i]
+			b := collider.Points[(i+1)%len(collider.Points)]
+			if segmentsIntersect(from, to, a, b) {
+				return false
+			}
+		}
 	}
-	return spawn
+	return true
 }
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineOfSight(t *testing.T) {
+	m := &Map{
+		Collider: []*Collider{
+			{
+				Points:     []*Point{{X: -1, Y: -1}, {X: 1, Y: -1}, {X: 1, Y: 1}, {X: -1, Y: 1}},
+				Projectile: true,
+			},
+			{ // projectiles fly across water
+				Points: []*Point{{X: 9, Y: -1}, {X: 11, Y: -1}, {X: 11, Y: 1}, {X: 9, Y: 1}},
+			},
+		},
+	}
+
+	assert.Equal(t, false, m.LineOfSight(&Point{X: -5, Y: 0}, &Point{X: 5, Y: 0}))
+	assert.Equal(t, true, m.LineOfSight(&Point{X: -5, Y: 2}, &Point{X: 5, Y: 2}))
+	assert.Equal(t, true, m.LineOfSight(&Point{X: 5, Y: 0}, &Point{X: 15, Y: 0}))
+	assert.Equal(t, false, m.LineOfSight(&Point{X: 0, Y: 0}, &Point{X: 0, Y: 0.5}))
+}
+
+func TestGetRandomSpawnWithMorePlayersThanSpawns(t *testing.T) {
+	game := NewGameState("test")
+	game.Seed(42)
+	used := map[*Point]int{}
+	for id := 1; id <= len(game.Map.Spawns)+1; id++ {
+		p := NewPlayer(id, 0, 0, nil)
+		spawn := game.GetRandomSpawn(game.GetPlayers())
+		assert.NotNil(t, spawn)
+		used[spawn]++
+		p.Respawn(spawn)
+		game.AddPlayer(p)
+	}
+
+	// every spawn is used once before one is shared
+	assert.Equal(t, len(game.Map.Spawns), len(used))
+}
@@ -2,7 +2,10 @@ package model
 
 import (
 	"fmt"
+	"net/url"
 	"time"
+
+	"github.com/awdng/triebwerk/rating"
 )
 
 // Connection represents the network connection of the player
@@ -14,11 +17,29 @@ type Connection interface {
 	PrepareRead(maxMessageSize int64, pongWait time.Duration)
 	Read() ([]byte, error)
 	Identifier() string
+	// Params the client supplied when opening the connection, eg. URL query parameters
+	Params() url.Values
+}
+
+// UnreliableWriter is implemented by connections that can send data without delivery guarantees
+type UnreliableWriter interface {
+	WriteUnreliable(data []byte) error
 }
 
+const (
+	// DefaultReliableQueueSize of messages waiting for a client, the client is disconnected when exceeded
+	DefaultReliableQueueSize = 100
+	// DefaultUnreliableQueueSize of state updates waiting for a client, the oldest update is dropped when exceeded
+	DefaultUnreliableQueueSize = 3
+)
+
 const respawnTime = 3
-const width = 5
-const depth = 7
+
+// TankWidth and TankDepth are the footprint of a Player's tank
+const (
+	TankWidth = 5
+	TankDepth = 7
+)
 
 // Controls ...
 type Controls struct {
@@ -34,12 +55,24 @@ type Controls struct {
 
 // Player ...
 type Player struct {
-	ID               int
-	GlobalID         string
-	AuthToken        string
-	Nickname         string
-	Health           int
-	Score            int
+	ID          int
+	GlobalID    string
+	AuthToken   string
+	ResumeToken string
+	Nickname    string
+	// Bot Players are controlled by the server and have no connection
+	Bot bool
+	// Rating of the Player's skill, loaded from storage when it joins
+	Rating float64
+	Health int
+	Score  int
+	Stats  MatchStats
+	// Damage the Player took during its current life
+	Damage DamageLedger
+	// kills of the Player that were not taken by the game yet
+	kills []Kill
+	// now is the game time of the current update
+	now              time.Time
 	respawnCountdown float32
 	Weapons          []*Weapon
 	Control          Controls
@@ -47,17 +80,17 @@ type Player struct {
 	Client           *Client
 }
 
-// NewPlayer creates a new player object
-func NewPlayer(id int, x float32, y float32, conn Connection) *Player {
+// NewPlayer creates a new player object, players without a connection get an offline Client
+func NewPlayer(id int, x float32, y float32, client *Client) *Player {
+	if client == nil {
+		client = NewClient(nil, DefaultReliableQueueSize, DefaultUnreliableQueueSize)
+	}
 	player := &Player{
 		ID:       id,
+		Rating:   rating.Default,
 		Health:   100,
-		Collider: NewRectCollider(x, y, width, depth),
-		Client: &Client{
-			NetworkOut: make(chan []byte, 100),
-			NetworkIn:  make(chan NetworkMessage, 100),
-			Connection: conn,
-		},
+		Collider: NewRectCollider(x, y, TankWidth, TankDepth),
+		Client:   client,
 	}
 	player.Weapons = []*Weapon{NewWeapon(player)}
 	return player
@@ -66,6 +99,7 @@ func NewPlayer(id int, x float32, y float32, conn Connection) *Player {
 // Update Tick for Player
 func (p *Player) Update(players []*Player, game *GameState, dt float32) {
 	m := game.Map
+	p.now = game.Clock().Now()
 	if !p.IsAlive() {
 		p.respawnCountdown += dt
 		return
@@ -77,18 +111,31 @@ func (p *Player) Update(players []*Player, game *GameState, dt float32) {
 
 // HandleRespawn ...
 func (p *Player) HandleRespawn(game *GameState) {
-	m := game.Map
 	if !p.IsAlive() && p.respawnCountdown > respawnTime {
-		spawn := m.GetRandomSpawn(game.GetPlayers())
-		p.Health = 100
-		p.respawnCountdown = 0
-
-		p.Collider.ChangePosition(spawn.X, spawn.Y)
-		p.Collider.Rotation = 0
-		p.Collider.TurretRotation = 0
+		p.Respawn(game.GetRandomSpawn(game.GetPlayers()))
 	}
 }
 
+// Respawn the Player at a spawn point with full health and a new tank, that neither keeps the rotation
+// nor the velocity of the destroyed one. A replay respawns its Players the same way, so they do not diverge
+func (p *Player) Respawn(spawn *Point) {
+	p.Health = 100
+	p.respawnCountdown = 0
+	p.Damage.Clear()
+	p.Collider = NewRectCollider(spawn.X, spawn.Y, TankWidth, TankDepth)
+}
+
+// Reset the Player for a new game, without the controls and projectiles of the last one. A replay
+// starts from new Players, the Players of a live game have to be in the same state when it starts
+func (p *Player) Reset(spawn *Point) {
+	p.Respawn(spawn)
+	p.Score = 0
+	p.Stats = MatchStats{}
+	p.kills = nil
+	p.Control = Controls{}
+	p.Weapons = []*Weapon{NewWeapon(p)}
+}
+
 // HandleWeapons ...
 func (p *Player) HandleWeapons(players []*Player, m *Map, dt float32) {
 	for _, w := range p.Weapons {
@@ -223,14 +270,38 @@ func (p *Player) IsAlive() bool {
 
 // Client represents a network client
 type Client struct {
+	// NetworkOut queues reliable messages that are delivered in order
 	NetworkOut chan []byte
+	// StateOut queues unreliable state updates that are superseded by newer ones
+	StateOut chan []byte
+	// LatestOut holds the latest of a message like the scoreboard, a newer one replaces it
+	LatestOut  chan []byte
 	NetworkIn  chan NetworkMessage
 	Connection Connection
 }
 
+// NewClient creates a network client for a connection, queue sizes below 1 use the defaults
+func NewClient(conn Connection, reliableQueueSize, unreliableQueueSize int) *Client {
+	if reliableQueueSize < 1 {
+		reliableQueueSize = DefaultReliableQueueSize
+	}
+	if unreliableQueueSize < 1 {
+		unreliableQueueSize = DefaultUnreliableQueueSize
+	}
+	return &Client{
+		NetworkOut: make(chan []byte, reliableQueueSize),
+		StateOut:   make(chan []byte, unreliableQueueSize),
+		LatestOut:  make(chan []byte, 1),
+		NetworkIn:  make(chan NetworkMessage, 100),
+		Connection: conn,
+	}
+}
+
 // Disconnect Client from the network
 func (c *Client) Disconnect() {
 	close(c.NetworkOut)
+	close(c.StateOut)
+	close(c.LatestOut)
 	close(c.NetworkIn)
 }
 
@@ -243,3 +314,10 @@ type NetworkMessage struct {
 func (m NetworkMessage) String() string {
 	return fmt.Sprintf("NetworkMessage %d - %+v", m.MessageType, m.Body)
 }
+
+// TakeKills returns the kills of the Player since the last call
+func (p *Player) TakeKills() []Kill {
+	kills := p.kills
+	p.kills = nil
+	return kills
+}
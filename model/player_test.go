@@ -6,6 +6,29 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestRespawnAndResetStartFromNewTank(t *testing.T) {
+	m := NewMap()
+	player := NewPlayer(1, 10, 10, nil)
+	player.Control = Controls{Forward: true, Left: true}
+	player.HandleMovement([]*Player{}, m, 1)
+	player.Weapons[0].ShootAt(0, 0)
+	player.Score = 3
+
+	spawn := &Point{X: 50, Y: 60}
+	fresh := NewPlayer(1, spawn.X, spawn.Y, nil)
+	player.Respawn(spawn)
+	assert.Equal(t, fresh.Collider, player.Collider)
+	assert.Equal(t, 3, player.Score)
+	assert.Equal(t, 1, len(player.Weapons[0].Projectiles))
+
+	player.Reset(spawn)
+	assert.Equal(t, fresh.Collider, player.Collider)
+	assert.Equal(t, Controls{}, player.Control)
+	assert.Equal(t, 0, player.Score)
+	assert.Equal(t, 0, len(player.Weapons[0].Projectiles))
+	assert.True(t, player.Weapons[0].Ready())
+}
+
 func TestPlayerMovement(t *testing.T) {
 	m := NewMap()
 	player1 := NewPlayer(1, 10, 10, nil)
@@ -25,3 +48,13 @@ func TestPlayerMovement(t *testing.T) {
 	assert.Equal(t, float32(24.962425), player1.Collider.Pivot.X)
 	assert.Equal(t, float32(26.061052), player1.Collider.Pivot.Y)
 }
+
+func TestNewPlayerKeepsClient(t *testing.T) {
+	client := NewClient(nil, 5, 2)
+	assert.Equal(t, client, NewPlayer(1, 0, 0, client).Client)
+
+	// players without a connection get an offline client with the default queues
+	offline := NewPlayer(2, 0, 0, nil).Client
+	assert.Nil(t, offline.Connection)
+	assert.Equal(t, DefaultReliableQueueSize, cap(offline.NetworkOut))
+}
@@ -46,3 +46,20 @@ func normalize(v *Point) {
 	v.X = v.X / float32(length)
 	v.Y = v.Y / float32(length)
 }
+
+// DistanceTo another Point
+func (p *Point) DistanceTo(v *Point) float32 {
+	return float32(math.Hypot(float64(v.X-p.X), float64(v.Y-p.Y)))
+}
+
+// segmentsIntersect returns true if the segment p1-p2 crosses the segment q1-q2
+func segmentsIntersect(p1, p2, q1, q2 *Point) bool {
+	cross := func(o, a, b *Point) float32 {
+		return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+	}
+	d1 := cross(q1, q2, p1)
+	d2 := cross(q1, q2, p2)
+	d3 := cross(p1, p2, q1)
+	d4 := cross(p1, p2, q2)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
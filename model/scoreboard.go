@@ -0,0 +1,75 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// ScoreboardEntry of a Player, Placement 1 is the best and Players with the same score share a placement
+type ScoreboardEntry struct {
+	ID          int
+	Nickname    string
+	Placement   int
+	Score       int
+	Kills       int
+	Deaths      int
+	Assists     int
+	DamageDealt int
+}
+
+// Results of a finished match
+type Results struct {
+	Scoreboard []ScoreboardEntry
+	// Winner is the ID of the only Player with the highest score, 0 on a draw or without score
+	Winner int
+	// NextMatch starts after this countdown
+	NextMatch time.Duration
+}
+
+// Scoreboard of the game ordered by score, Players with fewer deaths first on equal score
+func (g *GameState) Scoreboard() []ScoreboardEntry {
+	players := g.GetPlayers()
+	scoreboard := make([]ScoreboardEntry, 0, len(players))
+	for _, p := range players {
+		scoreboard = append(scoreboard, ScoreboardEntry{
+			ID:          p.ID,
+			Nickname:    p.Nickname,
+			Score:       p.Score,
+			Kills:       p.Stats.Kills,
+			Deaths:      p.Stats.Deaths,
+			Assists:     p.Stats.Assists,
+			DamageDealt: p.Stats.DamageDealt,
+		})
+	}
+	sort.Slice(scoreboard, func(i, j int) bool {
+		a, b := scoreboard[i], scoreboard[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Deaths != b.Deaths {
+			return a.Deaths < b.Deaths
+		}
+		return a.ID < b.ID
+	})
+	for i := range scoreboard {
+		if i > 0 && scoreboard[i].Score == scoreboard[i-1].Score {
+			scoreboard[i].Placement = scoreboard[i-1].Placement
+			continue
+		}
+		scoreboard[i].Placement = i + 1
+	}
+	return scoreboard
+}
+
+// Results of the game with the countdown to the next match
+func (g *GameState) Results(nextMatch time.Duration) Results {
+	results := Results{
+		Scoreboard: g.Scoreboard(),
+		NextMatch:  nextMatch,
+	}
+	board := results.Scoreboard
+	if len(board) > 0 && board[0].Score > 0 && (len(board) == 1 || board[1].Placement != 1) {
+		results.Winner = board[0].ID
+	}
+	return results
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreboard(t *testing.T) {
+	game := NewGameState("test")
+	for id, score := range map[int]int{1: 2, 2: 5, 3: 2, 4: 0} {
+		p := NewPlayer(id, 0, 0, nil)
+		p.Score = score
+		game.AddPlayer(p)
+	}
+	game.players[1].Stats.Deaths = 3
+	game.players[3].Stats.Deaths = 1
+
+	ids, placements := []int{}, []int{}
+	for _, entry := range game.Scoreboard() {
+		ids = append(ids, entry.ID)
+		placements = append(placements, entry.Placement)
+	}
+	assert.Equal(t, []int{2, 3, 1, 4}, ids)
+	assert.Equal(t, []int{1, 2, 2, 4}, placements)
+
+	results := game.Results(10 * time.Second)
+	assert.Equal(t, 2, results.Winner)
+	assert.Equal(t, 10*time.Second, results.NextMatch)
+
+	// nobody wins a draw
+	game.players[3].Score = 5
+	assert.Equal(t, 0, game.Results(0).Winner)
+	assert.Equal(t, 0, NewGameState("test").Results(0).Winner)
+}
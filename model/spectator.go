@@ -0,0 +1,18 @@
+package model
+
+// Spectator receives the game state without taking part in the game
+type Spectator struct {
+	ID int
+	// Follow is the ID of the Player the spectator camera follows, 0 for a free camera
+	Follow int
+	Client *Client
+}
+
+// NewSpectator creates a new spectator object
+func NewSpectator(id int, follow int, client *Client) *Spectator {
+	return &Spectator{
+		ID:     id,
+		Follow: follow,
+		Client: client,
+	}
+}
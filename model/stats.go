@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// MatchStats of a Player in the current match
+type MatchStats struct {
+	Kills      int
+	Deaths     int
+	ShotsFired int
+	Hits       int
+	Assists    int
+	// DamageDealt to other Players
+	DamageDealt int
+	// PlayTime the Player was connected during the match
+	PlayTime time.Duration
+}
+
+// Accuracy is the share of shots that hit, 0 without shots
+func (s MatchStats) Accuracy() float64 {
+	if s.ShotsFired == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.ShotsFired)
+}
@@ -30,11 +30,7 @@ func (w *Weapon) Update(players []*Player, m *Map, dt float32) {
 				continue
 			}
 			if b.IsCollidingWithPlayer(enemy) {
-				enemy.Health -= 25
-				if enemy.Health <= 0 {
-					enemy.Health = 0
-					w.owner.Score++
-				}
+				w.damage(enemy, players)
 				b.Cleanup = true
 				break
 			}
@@ -65,6 +61,45 @@ func (w *Weapon) Update(players []*Player, m *Map, dt float32) {
 	}
 }
 
+// damage an enemy hit by a projectile, the owner kills the enemy if its health runs out
+func (w *Weapon) damage(enemy *Player, players []*Player) {
+	amount := cannonDamage
+	if amount > enemy.Health {
+		amount = enemy.Health
+	}
+	enemy.Health -= amount
+	enemy.Damage.Add(Damage{Attacker: w.owner.ID, Amount: amount, Weapon: WeaponCannon, At: w.owner.now})
+	w.owner.Stats.Hits++
+	w.owner.Stats.DamageDealt += amount
+	if enemy.Health > 0 {
+		return
+	}
+
+	w.owner.Score++
+	w.owner.Stats.Kills++
+	enemy.Stats.Deaths++
+	kill := Kill{
+		Killer:  w.owner.ID,
+		Victim:  enemy.ID,
+		Assists: enemy.Damage.Assists(w.owner.ID),
+		Weapon:  WeaponCannon,
+		At:      w.owner.now,
+	}
+	for _, p := range players {
+		for _, id := range kill.Assists {
+			if p.ID == id {
+				p.Stats.Assists++
+			}
+		}
+	}
+	w.owner.kills = append(w.owner.kills, kill)
+}
+
+// Ready returns true if the weapon cooled down and can shoot
+func (w *Weapon) Ready() bool {
+	return w.ready
+}
+
 // ShootAt ...
 func (w *Weapon) ShootAt(posX float32, posY float32) {
 	if w.ready {
@@ -78,5 +113,6 @@ func (w *Weapon) ShootAt(posX float32, posY float32) {
 		projectile.Direction = projectile.Position.DirectionTo(w.owner.Collider.Pivot)
 		w.Projectiles = append(w.Projectiles, projectile)
 		w.ready = false
+		w.owner.Stats.ShotsFired++
 	}
 }
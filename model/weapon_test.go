@@ -0,0 +1,80 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeaponCooldown(t *testing.T) {
+	m := NewMap()
+	player := NewPlayer(1, 10, 10, nil)
+	weapon := player.Weapons[0]
+
+	assert.Equal(t, true, weapon.Ready())
+	weapon.ShootAt(player.Collider.Turret.X, player.Collider.Turret.Y)
+	assert.Equal(t, false, weapon.Ready())
+	assert.Equal(t, 1, len(weapon.Projectiles))
+
+	// weapon can not shoot again before it is ready
+	weapon.ShootAt(player.Collider.Turret.X, player.Collider.Turret.Y)
+	assert.Equal(t, 1, len(weapon.Projectiles))
+
+	weapon.Update([]*Player{player}, m, 1)
+	assert.Equal(t, false, weapon.Ready())
+	weapon.Update([]*Player{player}, m, 0.5)
+	assert.Equal(t, true, weapon.Ready())
+}
+
+func TestWeaponStats(t *testing.T) {
+	m := NewMap()
+	player := NewPlayer(1, 10, 10, nil)
+	enemy := NewPlayer(2, 50, 50, nil)
+	enemy.Health = 50
+	weapon := player.Weapons[0]
+
+	weapon.ShootAt(player.Collider.Turret.X, player.Collider.Turret.Y)
+	assert.Equal(t, 1, player.Stats.ShotsFired)
+
+	// projectiles in the enemy tank hit it
+	for i := 0; i < 2; i++ {
+		weapon.Projectiles = append(weapon.Projectiles, &Projectile{Position: &Point{X: 50, Y: 50}, Direction: &Point{X: 1}})
+		weapon.Update([]*Player{player, enemy}, m, 0)
+	}
+	assert.Equal(t, 2, player.Stats.Hits)
+	assert.Equal(t, 1, player.Stats.Kills)
+	assert.Equal(t, 1, enemy.Stats.Deaths)
+
+	player.Reset(&Point{X: 10, Y: 10})
+	assert.Equal(t, MatchStats{}, player.Stats)
+	assert.Equal(t, 0.0, player.Stats.Accuracy())
+	assert.Equal(t, 0.25, MatchStats{ShotsFired: 4, Hits: 1}.Accuracy())
+}
+
+func TestWeaponKillAssists(t *testing.T) {
+	m := NewMap()
+	player := NewPlayer(1, 10, 10, nil)
+	helper := NewPlayer(2, 90, 90, nil)
+	enemy := NewPlayer(3, 50, 50, nil)
+	players := []*Player{player, helper, enemy}
+	hit := func(w *Weapon) {
+		w.Projectiles = append(w.Projectiles, &Projectile{Position: &Point{X: 50, Y: 50}, Direction: &Point{X: 1}})
+		w.Update(players, m, 0)
+	}
+
+	hit(helper.Weapons[0])
+	for i := 0; i < 3; i++ {
+		hit(player.Weapons[0])
+	}
+	assert.Equal(t, 4, len(enemy.Damage.Entries()))
+	assert.Equal(t, Damage{Attacker: 2, Amount: 25, Weapon: WeaponCannon}, enemy.Damage.Entries()[0])
+	assert.Equal(t, 75, player.Stats.DamageDealt)
+	assert.Equal(t, 1, helper.Stats.Assists)
+	assert.Equal(t, 0, player.Stats.Assists)
+	assert.Equal(t, []Kill{{Killer: 1, Victim: 3, Assists: []int{2}, Weapon: WeaponCannon}}, player.TakeKills())
+	assert.Equal(t, 0, len(player.TakeKills()))
+
+	// the ledger starts over with the next life
+	enemy.Respawn(&Point{X: 50, Y: 50})
+	assert.Equal(t, 0, len(enemy.Damage.Entries()))
+}
@@ -0,0 +1,81 @@
+package nav
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// debugGraph is the JSON export of a Grid, nodes are the free cells and edges connect neighbouring nodes
+type debugGraph struct {
+	MapID    string        `json:"map"`
+	Origin   model.Point   `json:"origin"`
+	CellSize float32       `json:"cell_size"`
+	Cols     int           `json:"cols"`
+	Rows     int           `json:"rows"`
+	Nodes    []debugNode   `json:"nodes"`
+	Edges    [][2]int      `json:"edges"`
+	Path     []model.Point `json:"path,omitempty"`
+}
+
+type debugNode struct {
+	ID int     `json:"id"`
+	X  float32 `json:"x"`
+	Y  float32 `json:"y"`
+}
+
+// Export the graph of the Grid as JSON for debugging, path is optional
+func (g *Grid) Export(w io.Writer, path []*model.Point) error {
+	graph := debugGraph{
+		MapID:    g.Map.ID,
+		Origin:   g.Origin,
+		CellSize: g.CellSize,
+		Cols:     g.Cols,
+		Rows:     g.Rows,
+		Nodes:    []debugNode{},
+		Edges:    [][2]int{},
+	}
+	for row := 0; row < g.Rows; row++ {
+		for col := 0; col < g.Cols; col++ {
+			c := Cell{col, row}
+			if g.Blocked(c) {
+				continue
+			}
+			center := g.Center(c)
+			id := row*g.Cols + col
+			graph.Nodes = append(graph.Nodes, debugNode{ID: id, X: center.X, Y: center.Y})
+			// only edges to the right and up, the graph is undirected
+			for _, n := range []Cell{{1, 0}, {0, 1}, {1, 1}, {-1, 1}} {
+				next := Cell{col + n.Col, row + n.Row}
+				if !g.Blocked(next) {
+					graph.Edges = append(graph.Edges, [2]int{id, next.Row*g.Cols + next.Col})
+				}
+			}
+		}
+	}
+	for _, p := range path {
+		graph.Path = append(graph.Path, *p)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(graph)
+}
+
+// String renders the Grid as text, blocked cells are #, rows are printed top down
+func (g *Grid) String() string {
+	var b strings.Builder
+	for row := g.Rows - 1; row >= 0; row-- {
+		for col := 0; col < g.Cols; col++ {
+			if g.Blocked(Cell{col, row}) {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
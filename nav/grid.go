@@ -0,0 +1,147 @@
+package nav
+
+import (
+	"math"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// DefaultCellSize of a Grid in world units
+const DefaultCellSize = 2.5
+
+// Clearance a tank keeps to walls, the radius of the circle around its footprint
+var Clearance = float32(math.Hypot(model.TankWidth, model.TankDepth) / 2)
+
+// Cell of a Grid
+type Cell struct {
+	Col int
+	Row int
+}
+
+// Grid is a walkability grid over a Map, a cell is blocked if a tank
+// standing in its center would touch a collider
+type Grid struct {
+	Map      *model.Map
+	Origin   model.Point
+	CellSize float32
+	Cols     int
+	Rows     int
+	blocked  []bool
+}
+
+// NewGrid builds the Grid of a Map, the Grid covers the bounds of all colliders and spawns
+func NewGrid(m *model.Map, cellSize float32) *Grid {
+	min, max := bounds(m)
+	g := &Grid{
+		Map:      m,
+		Origin:   min,
+		CellSize: cellSize,
+		Cols:     int(math.Ceil(float64((max.X - min.X) / cellSize))),
+		Rows:     int(math.Ceil(float64((max.Y - min.Y) / cellSize))),
+	}
+	g.blocked = make([]bool, g.Cols*g.Rows)
+	for row := 0; row < g.Rows; row++ {
+		for col := 0; col < g.Cols; col++ {
+			g.blocked[row*g.Cols+col] = collides(m, g.Center(Cell{col, row}), Clearance)
+		}
+	}
+	return g
+}
+
+func bounds(m *model.Map) (model.Point, model.Point) {
+	points := []*model.Point{}
+	for _, c := range m.Collider {
+		points = append(points, c.Points...)
+	}
+	points = append(points, m.Spawns...)
+
+	min := model.Point{X: math.MaxFloat32, Y: math.MaxFloat32}
+	max := model.Point{X: -math.MaxFloat32, Y: -math.MaxFloat32}
+	for _, p := range points {
+		min.X = float32(math.Min(float64(min.X), float64(p.X)))
+		min.Y = float32(math.Min(float64(min.Y), float64(p.Y)))
+		max.X = float32(math.Max(float64(max.X), float64(p.X)))
+		max.Y = float32(math.Max(float64(max.Y), float64(p.Y)))
+	}
+	if len(points) == 0 {
+		return model.Point{}, model.Point{}
+	}
+	return min, max
+}
+
+// collides returns true if a circle around p touches any collider of the Map
+func collides(m *model.Map, p *model.Point, radius float32) bool {
+	for _, c := range m.Collider {
+		if p.IsInPolygon(c.Points) {
+			return true
+		}
+		for i := range c.Points {
+			if distanceToSegment(p, c.Points[i], c.Points[(i+1)%len(c.Points)]) < radius {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// distanceToSegment from p to the segment a-b
+func distanceToSegment(p, a, b *model.Point) float32 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lengthSquared := dx*dx + dy*dy
+	t := float32(0)
+	if lengthSquared > 0 {
+		t = ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / lengthSquared
+		if t < 0 {
+			t = 0
+		}
+		if t > 1 {
+			t = 1
+		}
+	}
+	return p.DistanceTo(&model.Point{X: a.X + t*dx, Y: a.Y + t*dy})
+}
+
+// CellAt returns the Cell containing a Point, false if it is outside of the Grid
+func (g *Grid) CellAt(p *model.Point) (Cell, bool) {
+	cell := Cell{
+		Col: int(math.Floor(float64((p.X - g.Origin.X) / g.CellSize))),
+		Row: int(math.Floor(float64((p.Y - g.Origin.Y) / g.CellSize))),
+	}
+	return cell, g.inside(cell)
+}
+
+// Center of a Cell in world coordinates
+func (g *Grid) Center(c Cell) *model.Point {
+	return &model.Point{
+		X: g.Origin.X + (float32(c.Col)+0.5)*g.CellSize,
+		Y: g.Origin.Y + (float32(c.Row)+0.5)*g.CellSize,
+	}
+}
+
+// Blocked returns true if a tank can not stand in the Cell
+func (g *Grid) Blocked(c Cell) bool {
+	return !g.inside(c) || g.blocked[c.Row*g.Cols+c.Col]
+}
+
+func (g *Grid) inside(c Cell) bool {
+	return c.Col >= 0 && c.Row >= 0 && c.Col < g.Cols && c.Row < g.Rows
+}
+
+// Walkable returns true if a tank can drive in a straight line between two Points
+func (g *Grid) Walkable(from, to *model.Point) bool {
+	distance := from.DistanceTo(to)
+	steps := int(math.Ceil(float64(distance/(g.CellSize/2)))) + 1
+	for i := 0; i <= steps; i++ {
+		t := float32(i) / float32(steps)
+		cell, ok := g.CellAt(&model.Point{X: from.X + (to.X-from.X)*t, Y: from.Y + (to.Y-from.Y)*t})
+		if !ok || g.Blocked(cell) {
+			return false
+		}
+	}
+	return true
+}
+
+// LineOfSight returns true if a projectile can fly between two Points
+func (g *Grid) LineOfSight(from, to *model.Point) bool {
+	return g.Map.LineOfSight(from, to)
+}
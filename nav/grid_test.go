@@ -0,0 +1,95 @@
+package nav
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// wallMap has a wall across the middle with a gap at the top
+func wallMap() *model.Map {
+	return &model.Map{
+		ID: "wall",
+		Collider: []*model.Collider{
+			{Points: []*model.Point{{X: -50, Y: -50}, {X: 50, Y: -50}, {X: 50, Y: -49}, {X: -50, Y: -49}}},
+			{Points: []*model.Point{{X: -50, Y: 50}, {X: 50, Y: 50}, {X: 50, Y: 49}, {X: -50, Y: 49}}},
+			{Points: []*model.Point{{X: -1, Y: -50}, {X: 1, Y: -50}, {X: 1, Y: 25}, {X: -1, Y: 25}}, Projectile: true},
+		},
+	}
+}
+
+func TestGridBlocksFootprint(t *testing.T) {
+	grid := NewGrid(wallMap(), DefaultCellSize)
+
+	wall, ok := grid.CellAt(&model.Point{X: 0, Y: 0})
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, grid.Blocked(wall))
+	// a tank does not fit right next to the wall
+	nextToWall, _ := grid.CellAt(&model.Point{X: 3, Y: 0})
+	assert.Equal(t, true, grid.Blocked(nextToWall))
+	free, _ := grid.CellAt(&model.Point{X: 20, Y: 0})
+	assert.Equal(t, false, grid.Blocked(free))
+	assert.Equal(t, true, grid.Blocked(Cell{-1, 0}))
+}
+
+func TestFindPathAroundWall(t *testing.T) {
+	grid := NewGrid(wallMap(), DefaultCellSize)
+	from := &model.Point{X: -20, Y: 0}
+	to := &model.Point{X: 20, Y: 0}
+	assert.Equal(t, false, grid.Walkable(from, to))
+	assert.Equal(t, false, grid.LineOfSight(from, to))
+
+	path, ok := grid.FindPath(from, to)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, *to, *path[len(path)-1])
+
+	// the path passes through the gap and every leg can be driven straight
+	current := from
+	passedGap := false
+	for _, waypoint := range path {
+		assert.Equal(t, true, grid.Walkable(current, waypoint), "%v to %v", current, waypoint)
+		passedGap = passedGap || waypoint.Y > 25
+		current = waypoint
+	}
+	assert.Equal(t, true, passedGap)
+
+	// smoothing leaves only the corners
+	assert.True(t, len(path) <= 4, "%d waypoints", len(path))
+}
+
+func TestFindPathUnreachable(t *testing.T) {
+	m := wallMap()
+	m.Collider[2].Points[2].Y = 50
+	m.Collider[2].Points[3].Y = 50
+	grid := NewGrid(m, DefaultCellSize)
+
+	_, ok := grid.FindPath(&model.Point{X: -20, Y: 0}, &model.Point{X: 20, Y: 0})
+	assert.Equal(t, false, ok)
+}
+
+func TestFindPathOnDefaultMap(t *testing.T) {
+	m := model.NewMap()
+	grid := NewGrid(m, DefaultCellSize)
+
+	for _, spawn := range m.Spawns[1:] {
+		_, ok := grid.FindPath(m.Spawns[0], spawn)
+		assert.Equal(t, true, ok, "no path to spawn %v", *spawn)
+	}
+}
+
+func TestExport(t *testing.T) {
+	grid := NewGrid(wallMap(), 10)
+	path, _ := grid.FindPath(&model.Point{X: -20, Y: 0}, &model.Point{X: 20, Y: 0})
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, grid.Export(buf, path))
+	graph := debugGraph{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &graph))
+	assert.Equal(t, "wall", graph.MapID)
+	assert.NotEmpty(t, graph.Nodes)
+	assert.NotEmpty(t, graph.Edges)
+	assert.Equal(t, len(path), len(graph.Path))
+}
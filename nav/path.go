@@ -0,0 +1,162 @@
+package nav
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// maxSnapDistance in cells a blocked start or goal is moved to the closest free cell
+const maxSnapDistance = 4
+
+var neighbours = []Cell{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{1, 1}, {1, -1}, {-1, 1}, {-1, -1},
+}
+
+// FindPath returns waypoints from one Point to another around all colliders,
+// the start is not part of the path. Returns false if the goal can not be reached
+func (g *Grid) FindPath(from, to *model.Point) ([]*model.Point, bool) {
+	start, ok := g.closestFree(from)
+	if !ok {
+		return nil, false
+	}
+	goal, ok := g.closestFree(to)
+	if !ok {
+		return nil, false
+	}
+
+	cells, ok := g.search(start, goal)
+	if !ok {
+		return nil, false
+	}
+	if len(cells) == 0 { // already in the goal cell
+		return []*model.Point{{X: to.X, Y: to.Y}}, true
+	}
+
+	waypoints := make([]*model.Point, 0, len(cells))
+	for _, c := range cells {
+		waypoints = append(waypoints, g.Center(c))
+	}
+	if goalCell, _ := g.CellAt(to); goalCell == goal {
+		waypoints[len(waypoints)-1] = &model.Point{X: to.X, Y: to.Y}
+	}
+	return g.smooth(from, waypoints), true
+}
+
+// closestFree Cell to a Point
+func (g *Grid) closestFree(p *model.Point) (Cell, bool) {
+	cell, _ := g.CellAt(p)
+	if !g.Blocked(cell) {
+		return cell, true
+	}
+	best, found := Cell{}, false
+	bestDistance := float32(math.MaxFloat32)
+	for row := cell.Row - maxSnapDistance; row <= cell.Row+maxSnapDistance; row++ {
+		for col := cell.Col - maxSnapDistance; col <= cell.Col+maxSnapDistance; col++ {
+			c := Cell{col, row}
+			if g.Blocked(c) {
+				continue
+			}
+			if distance := p.DistanceTo(g.Center(c)); distance < bestDistance {
+				best, found, bestDistance = c, true, distance
+			}
+		}
+	}
+	return best, found
+}
+
+// search runs A* on the grid, diagonal moves must not cut corners of blocked cells
+func (g *Grid) search(start, goal Cell) ([]Cell, bool) {
+	index := func(c Cell) int {
+		return c.Row*g.Cols + c.Col
+	}
+	costs := map[int]float32{index(start): 0}
+	parents := map[int]Cell{}
+	closed := map[int]bool{}
+	open := &queue{}
+	heap.Push(open, &node{cell: start, priority: heuristic(start, goal)})
+
+	for open.Len() > 0 {
+		current := heap.Pop(open).(*node).cell
+		if current == goal {
+			path := []Cell{}
+			for c := goal; c != start; c = parents[index(c)] {
+				path = append([]Cell{c}, path...)
+			}
+			return path, true
+		}
+		if closed[index(current)] {
+			continue
+		}
+		closed[index(current)] = true
+
+		for _, n := range neighbours {
+			next := Cell{current.Col + n.Col, current.Row + n.Row}
+			if g.Blocked(next) || closed[index(next)] {
+				continue
+			}
+			step := float32(1)
+			if n.Col != 0 && n.Row != 0 {
+				if g.Blocked(Cell{current.Col + n.Col, current.Row}) || g.Blocked(Cell{current.Col, current.Row + n.Row}) {
+					continue
+				}
+				step = math.Sqrt2
+			}
+			cost := costs[index(current)] + step
+			if known, ok := costs[index(next)]; ok && known <= cost {
+				continue
+			}
+			costs[index(next)] = cost
+			parents[index(next)] = current
+			heap.Push(open, &node{cell: next, priority: cost + heuristic(next, goal)})
+		}
+	}
+	return nil, false
+}
+
+// heuristic is the octile distance between two cells
+func heuristic(a, b Cell) float32 {
+	dx := math.Abs(float64(a.Col - b.Col))
+	dy := math.Abs(float64(a.Row - b.Row))
+	return float32(math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy))
+}
+
+// smooth removes waypoints that can be skipped by driving straight
+func (g *Grid) smooth(from *model.Point, waypoints []*model.Point) []*model.Point {
+	smoothed := []*model.Point{}
+	current := from
+	for i := 0; i < len(waypoints); {
+		next := i
+		for j := len(waypoints) - 1; j > i; j-- {
+			if g.Walkable(current, waypoints[j]) {
+				next = j
+				break
+			}
+		}
+		smoothed = append(smoothed, waypoints[next])
+		current = waypoints[next]
+		i = next + 1
+	}
+	return smoothed
+}
+
+type node struct {
+	cell     Cell
+	priority float32
+}
+
+// queue is a priority queue of nodes for A*
+type queue []*node
+
+func (q queue) Len() int            { return len(q) }
+func (q queue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q queue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(*node)) }
+func (q *queue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
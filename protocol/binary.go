@@ -3,6 +3,7 @@ package protocol
 import (
 	"encoding/binary"
 	"math"
+	"time"
 
 	"github.com/awdng/triebwerk/model"
 )
@@ -24,10 +25,16 @@ func NewBinaryProtocol() BinaryProtocol {
 	protocol.encodeHandlers[1] = encodePlayerState
 	protocol.encodeHandlers[2] = encodePlayerRegister
 	protocol.encodeHandlers[5] = encodePlayerTime
+	protocol.encodeHandlers[8] = encodeSpectatorFollow
+	protocol.encodeHandlers[9] = encodeDisconnect
+	protocol.encodeHandlers[10] = encodeKill
+	protocol.encodeHandlers[11] = encodeScoreboard
+	protocol.encodeHandlers[12] = encodeResults
 
 	protocol.decodeHandlers[0] = decodePlayerAuth
 	protocol.decodeHandlers[1] = decodePlayerInput
 	protocol.decodeHandlers[5] = decodePlayerTime
+	protocol.decodeHandlers[8] = decodeSpectatorFollow
 
 	return protocol
 }
@@ -98,7 +105,10 @@ func encodePlayerState(message *model.NetworkMessage) []byte {
 }
 
 func encodePlayerRegister(message *model.NetworkMessage) []byte {
-	// for now do nothing
+	// the token to resume the session after a disconnect
+	if token, ok := message.Body.(string); ok {
+		return []byte(token)
+	}
 	return []byte{}
 }
 
@@ -109,8 +119,60 @@ func encodePlayerTime(message *model.NetworkMessage) []byte {
 	return time
 }
 
-func EncodePlayerInput() {
+func encodeSpectatorFollow(message *model.NetworkMessage) []byte {
+	return []byte{byte(uint8(message.Body.(int)))}
+}
+
+func encodeDisconnect(message *model.NetworkMessage) []byte {
+	// the reason the server closes the connection
+	return []byte(message.Body.(string))
+}
+
+func encodeKill(message *model.NetworkMessage) []byte {
+	// killer, victim, the assisting Players and the name of the weapon
+	kill := message.Body.(model.Kill)
+	buf := []byte{byte(uint8(kill.Killer)), byte(uint8(kill.Victim)), byte(uint8(len(kill.Assists)))}
+	for _, id := range kill.Assists {
+		buf = append(buf, byte(uint8(id)))
+	}
+	return append(buf, []byte(kill.Weapon)...)
+}
+
+func encodeScoreboard(message *model.NetworkMessage) []byte {
+	return appendScoreboard(make([]byte, 0), message.Body.([]model.ScoreboardEntry))
+}
+
+func encodeResults(message *model.NetworkMessage) []byte {
+	// the winner and the countdown to the next match in milliseconds, followed by the final scoreboard
+	results := message.Body.(model.Results)
+	buf := []byte{byte(uint8(results.Winner))}
+	nextMatch := make([]byte, 4)
+	binary.LittleEndian.PutUint32(nextMatch[:], uint32(results.NextMatch/time.Millisecond))
+	buf = append(buf, nextMatch...)
+	return appendScoreboard(buf, results.Scoreboard)
+}
 
+// appendScoreboard encodes the number of entries followed by the entries, the nickname
+// of an entry comes last with its length in front
+func appendScoreboard(buf []byte, scoreboard []model.ScoreboardEntry) []byte {
+	buf = append(buf, byte(uint8(len(scoreboard))))
+	value := make([]byte, 4)
+	for _, entry := range scoreboard {
+		buf = append(buf, byte(uint8(entry.ID)), byte(uint8(entry.Placement)))
+		for _, v := range []int{entry.Score, entry.Kills, entry.Deaths, entry.Assists} {
+			binary.LittleEndian.PutUint16(value[:2], uint16(v))
+			buf = append(buf, value[:2]...)
+		}
+		binary.LittleEndian.PutUint32(value[:], uint32(entry.DamageDealt))
+		buf = append(buf, value...)
+		nickname := entry.Nickname
+		if len(nickname) > math.MaxUint8 {
+			nickname = nickname[:math.MaxUint8]
+		}
+		buf = append(buf, byte(uint8(len(nickname))))
+		buf = append(buf, []byte(nickname)...)
+	}
+	return buf
 }
 
 func decodePlayerInput(data []byte, message *model.NetworkMessage) {
@@ -152,6 +214,13 @@ func decodePlayerTime(data []byte, message *model.NetworkMessage) {
 	message.Body = binary.BigEndian.Uint32(data[2:])
 }
 
+func decodeSpectatorFollow(data []byte, message *model.NetworkMessage) {
+	message.Body = 0
+	if len(data) > 2 {
+		message.Body = int(data[2])
+	}
+}
+
 func decodePlayerAuth(data []byte, message *model.NetworkMessage) {
 	message.Body = string(data[2:])
 }
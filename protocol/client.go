@@ -0,0 +1,159 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// Encoders for the messages clients send, the counterpart of the decode handlers.
+// Client messages start with the Player ID and the message type
+
+// headerSize of messages sent by the server: Player ID, message type and game time
+const headerSize = 6
+
+// EncodePlayerAuth encodes the auth token of a Player
+func EncodePlayerAuth(id int, token string) []byte {
+	buf := []byte{byte(uint8(id)), 0}
+	return append(buf, token...)
+}
+
+// EncodePlayerInput encodes the controls of a Player
+func EncodePlayerInput(id int, controls model.Controls) []byte {
+	buf := []byte{byte(uint8(id)), 1}
+	for _, pressed := range []bool{controls.Forward, controls.Backward, controls.Left, controls.Right, controls.TurretRight, controls.TurretLeft, controls.Shoot} {
+		if pressed {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+	}
+	sequence := make([]byte, 4)
+	binary.BigEndian.PutUint32(sequence, controls.Sequence)
+	return append(buf, sequence...)
+}
+
+// EncodePlayerTime encodes a time request, the server echoes the client time
+func EncodePlayerTime(id int, clientTime uint32) []byte {
+	buf := make([]byte, 6)
+	buf[0] = byte(uint8(id))
+	buf[1] = 5
+	binary.BigEndian.PutUint32(buf[2:], clientTime)
+	return buf
+}
+
+// EncodeSpectatorFollow encodes the ID of the Player a spectator wants to follow
+func EncodeSpectatorFollow(id int, follow int) []byte {
+	return []byte{byte(uint8(id)), 8, byte(uint8(follow))}
+}
+
+// EncodeScoreboardRequest requests a scoreboard from the server
+func EncodeScoreboardRequest(id int) []byte {
+	return []byte{byte(uint8(id)), 11}
+}
+
+// DecodeHeader of a message sent by the server, returns false if the message is too short
+func DecodeHeader(data []byte) (id int, messageType uint8, gameTime uint32, ok bool) {
+	if len(data) < headerSize {
+		return 0, 0, 0, false
+	}
+	return int(data[0]), data[1], binary.LittleEndian.Uint32(data[2:6]), true
+}
+
+// DecodeServerTime returns the client time the server echoed in a time message
+func DecodeServerTime(data []byte) (uint32, bool) {
+	if len(data) < headerSize+4 || data[1] != 5 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(data[headerSize:]), true
+}
+
+// DecodeDisconnectReason returns the reason of a disconnect message
+func DecodeDisconnectReason(data []byte) (string, bool) {
+	if len(data) < headerSize || data[1] != 9 {
+		return "", false
+	}
+	return string(data[headerSize:]), true
+}
+
+// DecodeKill returns the kill of a kill feed message, the time of the kill is not sent
+func DecodeKill(data []byte) (model.Kill, bool) {
+	if len(data) < headerSize+3 || data[1] != 10 {
+		return model.Kill{}, false
+	}
+	body := data[headerSize:]
+	assists := int(body[2])
+	if len(body) < 3+assists {
+		return model.Kill{}, false
+	}
+	kill := model.Kill{
+		Killer:  int(body[0]),
+		Victim:  int(body[1]),
+		Assists: []int{},
+		Weapon:  string(body[3+assists:]),
+	}
+	for _, id := range body[3 : 3+assists] {
+		kill.Assists = append(kill.Assists, int(id))
+	}
+	return kill, true
+}
+
+// scoreboardEntrySize without the nickname: ID, placement, four 16 bit counters, damage and nickname length
+const scoreboardEntrySize = 2 + 4*2 + 4 + 1
+
+// DecodeScoreboard returns the entries of a scoreboard message
+func DecodeScoreboard(data []byte) ([]model.ScoreboardEntry, bool) {
+	if len(data) < headerSize || data[1] != 11 {
+		return nil, false
+	}
+	return decodeScoreboard(data[headerSize:])
+}
+
+// DecodeResults returns the results of a finished match
+func DecodeResults(data []byte) (model.Results, bool) {
+	if len(data) < headerSize+5 || data[1] != 12 {
+		return model.Results{}, false
+	}
+	body := data[headerSize:]
+	scoreboard, ok := decodeScoreboard(body[5:])
+	if !ok {
+		return model.Results{}, false
+	}
+	return model.Results{
+		Scoreboard: scoreboard,
+		Winner:     int(body[0]),
+		NextMatch:  time.Duration(binary.LittleEndian.Uint32(body[1:5])) * time.Millisecond,
+	}, true
+}
+
+// decodeScoreboard returns the scoreboard encoded in data
+func decodeScoreboard(data []byte) ([]model.ScoreboardEntry, bool) {
+	if len(data) < 1 {
+		return nil, false
+	}
+	count := int(data[0])
+	data = data[1:]
+	scoreboard := make([]model.ScoreboardEntry, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < scoreboardEntrySize {
+			return nil, false
+		}
+		nicknameLength := int(data[scoreboardEntrySize-1])
+		if len(data) < scoreboardEntrySize+nicknameLength {
+			return nil, false
+		}
+		scoreboard = append(scoreboard, model.ScoreboardEntry{
+			ID:          int(data[0]),
+			Placement:   int(data[1]),
+			Score:       int(binary.LittleEndian.Uint16(data[2:4])),
+			Kills:       int(binary.LittleEndian.Uint16(data[4:6])),
+			Deaths:      int(binary.LittleEndian.Uint16(data[6:8])),
+			Assists:     int(binary.LittleEndian.Uint16(data[8:10])),
+			DamageDealt: int(binary.LittleEndian.Uint32(data[10:14])),
+			Nickname:    string(data[scoreboardEntrySize : scoreboardEntrySize+nicknameLength]),
+		})
+		data = data[scoreboardEntrySize+nicknameLength:]
+	}
+	return scoreboard, true
+}
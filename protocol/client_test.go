@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientMessagesRoundtrip(t *testing.T) {
+	protocol := NewBinaryProtocol()
+
+	auth := protocol.Decode(EncodePlayerAuth(0, "token"))
+	assert.Equal(t, uint8(0), auth.MessageType)
+	assert.Equal(t, "token", auth.Body)
+
+	controls := model.Controls{Forward: true, TurretLeft: true, Shoot: true, Sequence: 1234}
+	input := protocol.Decode(EncodePlayerInput(3, controls))
+	assert.Equal(t, uint8(1), input.MessageType)
+	assert.Equal(t, controls, input.Body)
+
+	follow := protocol.Decode(EncodeSpectatorFollow(3, 7))
+	assert.Equal(t, 7, follow.Body)
+
+	// the server echoes the client time of a time request
+	request := protocol.Decode(EncodePlayerTime(3, 987654))
+	response := protocol.Encode(3, 42, &request)
+	id, messageType, gameTime, ok := DecodeHeader(response)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 3, id)
+	assert.Equal(t, uint8(5), messageType)
+	assert.Equal(t, uint32(42), gameTime)
+	clientTime, ok := DecodeServerTime(response)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint32(987654), clientTime)
+}
+
+func TestDecodeKill(t *testing.T) {
+	protocol := NewBinaryProtocol()
+	kill := model.Kill{Killer: 1, Victim: 3, Assists: []int{2, 4}, Weapon: model.WeaponCannon}
+	decoded, ok := DecodeKill(protocol.Encode(0, 42, &model.NetworkMessage{MessageType: 10, Body: kill}))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, kill, decoded)
+
+	_, ok = DecodeKill(protocol.Encode(0, 42, &model.NetworkMessage{MessageType: 7}))
+	assert.Equal(t, false, ok)
+}
+
+func TestDecodeScoreboardAndResults(t *testing.T) {
+	protocol := NewBinaryProtocol()
+	scoreboard := []model.ScoreboardEntry{
+		{ID: 2, Nickname: "Alice", Placement: 1, Score: 5, Kills: 5, Deaths: 1, Assists: 2, DamageDealt: 150},
+		{ID: 1, Nickname: "Bot 1", Placement: 2, Score: 1, Kills: 1, Deaths: 5},
+	}
+
+	decoded, ok := DecodeScoreboard(protocol.Encode(0, 42, &model.NetworkMessage{MessageType: 11, Body: scoreboard}))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, scoreboard, decoded)
+
+	results := model.Results{Scoreboard: scoreboard, Winner: 2, NextMatch: 10 * time.Second}
+	decodedResults, ok := DecodeResults(protocol.Encode(0, 42, &model.NetworkMessage{MessageType: 12, Body: results}))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, results, decodedResults)
+
+	// truncated messages are rejected
+	data := protocol.Encode(0, 42, &model.NetworkMessage{MessageType: 11, Body: scoreboard})
+	_, ok = DecodeScoreboard(data[:len(data)-1])
+	assert.Equal(t, false, ok)
+
+	request := protocol.Decode(EncodeScoreboardRequest(3))
+	assert.Equal(t, uint8(11), request.MessageType)
+}
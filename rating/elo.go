@@ -0,0 +1,86 @@
+package rating
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// Default rating of players without a match
+	Default = 1500.0
+	// K is the most a rating can change by in a match against a single opponent
+	K = 32.0
+	// scale of the rating difference, a player rated scale points higher is expected to win 10 of 11 matches
+	scale = 400.0
+)
+
+// Result of a participant of a match. Placement 1 is the best, participants with the same placement tie
+type Result struct {
+	ID        string
+	Placement int
+}
+
+// Update the ratings of all participants from the placements of a free-for-all match with Elo.
+// It is rated as duels of every participant against every other, scaled by the number of opponents.
+// Participants without a rating start with the Default rating, the new ratings are returned
+func Update(ratings map[string]float64, results []Result) map[string]float64 {
+	updated := make(map[string]float64, len(results))
+	for _, r := range results {
+		updated[r.ID] = current(ratings, r.ID)
+	}
+	if len(results) < 2 {
+		return updated
+	}
+
+	opponents := float64(len(results) - 1)
+	for _, a := range results {
+		delta := 0.0
+		for _, b := range results {
+			if a.ID == b.ID {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (current(ratings, b.ID)-current(ratings, a.ID))/scale))
+			delta += K * (outcome(a.Placement, b.Placement) - expected)
+		}
+		updated[a.ID] += delta / opponents
+	}
+	return updated
+}
+
+func current(ratings map[string]float64, id string) float64 {
+	if rating, ok := ratings[id]; ok {
+		return rating
+	}
+	return Default
+}
+
+// outcome of a duel, 1 for a win, 0.5 for a tie and 0 for a loss
+func outcome(placement, opponent int) float64 {
+	switch {
+	case placement < opponent:
+		return 1
+	case placement == opponent:
+		return 0.5
+	}
+	return 0
+}
+
+// Placements from scores, the highest score is placed first and equal scores tie
+func Placements(scores map[string]int) map[string]int {
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return scores[ids[i]] > scores[ids[j]]
+	})
+	placements := make(map[string]int, len(ids))
+	for i, id := range ids {
+		if i > 0 && scores[id] == scores[ids[i-1]] {
+			placements[id] = placements[ids[i-1]]
+			continue
+		}
+		placements[id] = i + 1
+	}
+	return placements
+}
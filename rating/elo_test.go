@@ -0,0 +1,38 @@
+package rating
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateDuel(t *testing.T) {
+	updated := Update(nil, []Result{{ID: "a", Placement: 1}, {ID: "b", Placement: 2}})
+	assert.Equal(t, Default+K/2, updated["a"])
+	assert.Equal(t, Default-K/2, updated["b"])
+
+	// beating a stronger player is worth more
+	updated = Update(map[string]float64{"a": 1400, "b": 1600}, []Result{{ID: "a", Placement: 1}, {ID: "b", Placement: 2}})
+	assert.True(t, updated["a"]-1400 > K/2)
+	assert.InDelta(t, 0, updated["a"]-1400+updated["b"]-1600, 0.0001)
+
+	// equal players that tie keep their rating
+	updated = Update(nil, []Result{{ID: "a", Placement: 1}, {ID: "b", Placement: 1}})
+	assert.Equal(t, Default, updated["a"])
+	assert.Equal(t, Default, updated["b"])
+}
+
+func TestUpdateFreeForAll(t *testing.T) {
+	updated := Update(nil, []Result{{ID: "a", Placement: 1}, {ID: "b", Placement: 2}, {ID: "c", Placement: 3}})
+	assert.True(t, updated["a"] > updated["b"])
+	assert.Equal(t, Default, updated["b"])
+	assert.True(t, updated["b"] > updated["c"])
+	assert.InDelta(t, 3*Default, updated["a"]+updated["b"]+updated["c"], 0.0001)
+
+	// a single participant has nobody to be rated against
+	assert.Equal(t, map[string]float64{"a": 1600}, Update(map[string]float64{"a": 1600}, []Result{{ID: "a", Placement: 1}}))
+}
+
+func TestPlacements(t *testing.T) {
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 2, "d": 4}, Placements(map[string]int{"a": 9, "b": 5, "c": 5, "d": 0}))
+}
@@ -0,0 +1,392 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// Version of the replay format written by this package
+const Version = 1
+
+// magic identifies replay files
+var magic = []byte("TWRP")
+
+// ErrInvalidFile is returned when reading data that is not a replay
+var ErrInvalidFile = errors.New("replay: invalid file")
+
+// EventKind ...
+type EventKind uint8
+
+const (
+	// Join of a Player with its spawn position, takes effect after the inputs of its tick
+	Join EventKind = iota
+	// Leave of a Player, takes effect after the inputs of its tick
+	Leave
+	// Input of a Player that was applied in its tick
+	Input
+	// Spawn of a dead Player at a new position
+	Spawn
+	// Keyframe is a snapshot of all Players at the end of its tick
+	Keyframe
+	// End of the match
+	End
+)
+
+// Header describes the match and the rules it was played with
+type Header struct {
+	Version          uint16
+	MapID            string
+	Region           string
+	StartedAt        time.Time
+	Tickrate         int
+	Timestep         float32
+	GameLength       time.Duration
+	KeyframeInterval int
+}
+
+// PlayerState is the state of a Player in a keyframe
+type PlayerState struct {
+	ID             int
+	X              float32
+	Y              float32
+	Rotation       float32
+	TurretRotation float32
+	Health         int
+	Score          int
+}
+
+// NewPlayerState captures the state of a Player
+func NewPlayerState(p *model.Player) PlayerState {
+	return PlayerState{
+		ID:             p.ID,
+		X:              p.Collider.Pivot.X,
+		Y:              p.Collider.Pivot.Y,
+		Rotation:       p.Collider.Rotation,
+		TurretRotation: p.Collider.TurretRotation,
+		Health:         p.Health,
+		Score:          p.Score,
+	}
+}
+
+// Event recorded during a match, only the fields of its kind are set
+type Event struct {
+	Kind     EventKind
+	Tick     uint32
+	PlayerID int
+	GlobalID string
+	Nickname string
+	X        float32
+	Y        float32
+	Controls model.Controls
+	Players  []PlayerState
+}
+
+// Writer encodes a replay
+type Writer struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriter writes the header and returns a Writer for the events
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	writer := &Writer{w: w}
+	writer.buf = append(writer.buf, magic...)
+	writer.putUint16(Version)
+	writer.putString(header.MapID)
+	writer.putString(header.Region)
+	writer.putVarint(header.StartedAt.UnixNano() / int64(time.Millisecond))
+	writer.putUvarint(uint64(header.Tickrate))
+	writer.putFloat32(header.Timestep)
+	writer.putUvarint(uint64(header.GameLength / time.Millisecond))
+	writer.putUvarint(uint64(header.KeyframeInterval))
+	return writer, writer.flush()
+}
+
+// Write an event
+func (w *Writer) Write(e Event) error {
+	w.buf = append(w.buf, byte(e.Kind))
+	w.putUvarint(uint64(e.Tick))
+
+	switch e.Kind {
+	case Join:
+		w.putUvarint(uint64(e.PlayerID))
+		w.putString(e.GlobalID)
+		w.putString(e.Nickname)
+		w.putFloat32(e.X)
+		w.putFloat32(e.Y)
+	case Leave:
+		w.putUvarint(uint64(e.PlayerID))
+	case Input:
+		w.putUvarint(uint64(e.PlayerID))
+		w.buf = append(w.buf, encodeControls(e.Controls))
+		w.putUvarint(uint64(e.Controls.Sequence))
+	case Spawn:
+		w.putUvarint(uint64(e.PlayerID))
+		w.putFloat32(e.X)
+		w.putFloat32(e.Y)
+	case Keyframe:
+		w.putUvarint(uint64(len(e.Players)))
+		for _, p := range e.Players {
+			w.putUvarint(uint64(p.ID))
+			w.putFloat32(p.X)
+			w.putFloat32(p.Y)
+			w.putFloat32(p.Rotation)
+			w.putFloat32(p.TurretRotation)
+			w.putVarint(int64(p.Health))
+			w.putVarint(int64(p.Score))
+		}
+	case End:
+	default:
+		return fmt.Errorf("replay: unknown event kind %d", e.Kind)
+	}
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	_, err := w.w.Write(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *Writer) putUint16(v uint16) {
+	buf := make([]byte, 2)
+	binary.LittleEndian.PutUint16(buf, v)
+	w.buf = append(w.buf, buf...)
+}
+
+func (w *Writer) putFloat32(v float32) {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, math.Float32bits(v))
+	w.buf = append(w.buf, buf...)
+}
+
+func (w *Writer) putUvarint(v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.buf = append(w.buf, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func (w *Writer) putVarint(v int64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.buf = append(w.buf, buf[:binary.PutVarint(buf, v)]...)
+}
+
+func (w *Writer) putString(v string) {
+	w.putUvarint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+// Reader decodes a replay
+type Reader struct {
+	r      *bufio.Reader
+	Header Header
+}
+
+// NewReader reads the header of a replay
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r)}
+	buf := make([]byte, len(magic))
+	if _, err := io.ReadFull(reader.r, buf); err != nil || string(buf) != string(magic) {
+		return nil, ErrInvalidFile
+	}
+
+	header := Header{}
+	var err error
+	if header.Version, err = reader.uint16(); err != nil {
+		return nil, err
+	}
+	if header.Version != Version {
+		return nil, fmt.Errorf("replay: unsupported version %d", header.Version)
+	}
+	if header.MapID, err = reader.string(); err != nil {
+		return nil, err
+	}
+	if header.Region, err = reader.string(); err != nil {
+		return nil, err
+	}
+	startedAt, err := binary.ReadVarint(reader.r)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	header.StartedAt = time.Unix(0, startedAt*int64(time.Millisecond))
+	tickrate, err := binary.ReadUvarint(reader.r)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	header.Tickrate = int(tickrate)
+	if header.Timestep, err = reader.float32(); err != nil {
+		return nil, err
+	}
+	gameLength, err := binary.ReadUvarint(reader.r)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	header.GameLength = time.Duration(gameLength) * time.Millisecond
+	keyframeInterval, err := binary.ReadUvarint(reader.r)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	header.KeyframeInterval = int(keyframeInterval)
+
+	reader.Header = header
+	return reader, nil
+}
+
+// Next event of the replay, returns io.EOF after the last event
+func (r *Reader) Next() (Event, error) {
+	kind, err := r.r.ReadByte()
+	if err != nil {
+		return Event{}, err
+	}
+	e := Event{Kind: EventKind(kind)}
+	tick, err := r.uvarint()
+	e.Tick = uint32(tick)
+
+	switch e.Kind {
+	case Join:
+		e.PlayerID, err = r.id(err)
+		if err == nil {
+			e.GlobalID, err = r.string()
+		}
+		if err == nil {
+			e.Nickname, err = r.string()
+		}
+		e.X, e.Y, err = r.position(err)
+	case Leave:
+		e.PlayerID, err = r.id(err)
+	case Input:
+		e.PlayerID, err = r.id(err)
+		var controls byte
+		if err == nil {
+			controls, err = r.r.ReadByte()
+		}
+		e.Controls = decodeControls(controls)
+		var sequence uint64
+		if err == nil {
+			sequence, err = r.uvarint()
+		}
+		e.Controls.Sequence = uint32(sequence)
+	case Spawn:
+		e.PlayerID, err = r.id(err)
+		e.X, e.Y, err = r.position(err)
+	case Keyframe:
+		var count uint64
+		if err == nil {
+			count, err = r.uvarint()
+		}
+		for i := uint64(0); i < count && err == nil; i++ {
+			p := PlayerState{}
+			p.ID, err = r.id(err)
+			p.X, p.Y, err = r.position(err)
+			if err == nil {
+				p.Rotation, err = r.float32()
+			}
+			if err == nil {
+				p.TurretRotation, err = r.float32()
+			}
+			var health, score int64
+			if err == nil {
+				health, err = binary.ReadVarint(r.r)
+			}
+			if err == nil {
+				score, err = binary.ReadVarint(r.r)
+			}
+			p.Health = int(health)
+			p.Score = int(score)
+			e.Players = append(e.Players, p)
+		}
+	case End:
+	default:
+		return e, ErrInvalidFile
+	}
+
+	return e, unexpected(err)
+}
+
+func (r *Reader) id(err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	id, err := r.uvarint()
+	return int(id), err
+}
+
+func (r *Reader) position(err error) (float32, float32, error) {
+	if err != nil {
+		return 0, 0, err
+	}
+	x, err := r.float32()
+	if err != nil {
+		return 0, 0, err
+	}
+	y, err := r.float32()
+	return x, y, err
+}
+
+func (r *Reader) uvarint() (uint64, error) {
+	return binary.ReadUvarint(r.r)
+}
+
+func (r *Reader) uint16() (uint16, error) {
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return 0, unexpected(err)
+	}
+	return binary.LittleEndian.Uint16(buf), nil
+}
+
+func (r *Reader) float32() (float32, error) {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return 0, unexpected(err)
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(buf)), nil
+}
+
+func (r *Reader) string() (string, error) {
+	length, err := r.uvarint()
+	if err != nil {
+		return "", unexpected(err)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return "", unexpected(err)
+	}
+	return string(buf), nil
+}
+
+// unexpected turns an EOF in the middle of a record into an error
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func encodeControls(c model.Controls) byte {
+	var b byte
+	for i, pressed := range []bool{c.Forward, c.Backward, c.Left, c.Right, c.TurretLeft, c.TurretRight, c.Shoot} {
+		if pressed {
+			b |= 1 << uint(i)
+		}
+	}
+	return b
+}
+
+func decodeControls(b byte) model.Controls {
+	return model.Controls{
+		Forward:     b&(1<<0) != 0,
+		Backward:    b&(1<<1) != 0,
+		Left:        b&(1<<2) != 0,
+		Right:       b&(1<<3) != 0,
+		TurretLeft:  b&(1<<4) != 0,
+		TurretRight: b&(1<<5) != 0,
+		Shoot:       b&(1<<6) != 0,
+	}
+}
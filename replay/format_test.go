@@ -0,0 +1,55 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundtrip(t *testing.T) {
+	header := Header{
+		Version:          Version,
+		MapID:            "default",
+		Region:           "EU",
+		StartedAt:        time.Unix(1500000000, 0),
+		Tickrate:         30,
+		Timestep:         0.033,
+		GameLength:       5 * time.Minute,
+		KeyframeInterval: 30,
+	}
+	events := []Event{
+		{Kind: Join, Tick: 0, PlayerID: 1, GlobalID: "global", Nickname: "tank", X: 1.5, Y: -2},
+		{Kind: Input, Tick: 1, PlayerID: 1, Controls: model.Controls{Forward: true, Shoot: true, Sequence: 300}},
+		{Kind: Spawn, Tick: 2, PlayerID: 1, X: 3, Y: 4},
+		{Kind: Keyframe, Tick: 30, Players: []PlayerState{{ID: 1, X: 3, Y: 4, Rotation: 0.5, TurretRotation: -0.5, Health: 75, Score: 2}}},
+		{Kind: Leave, Tick: 31, PlayerID: 1},
+		{Kind: End, Tick: 32},
+	}
+
+	buf := &bytes.Buffer{}
+	writer, err := NewWriter(buf, header)
+	assert.Nil(t, err)
+	for _, e := range events {
+		assert.Nil(t, writer.Write(e))
+	}
+
+	reader, err := NewReader(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, header, reader.Header)
+	for _, e := range events {
+		read, err := reader.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, e, read)
+	}
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestInvalidFile(t *testing.T) {
+	_, err := NewReader(bytes.NewBufferString("not a replay"))
+	assert.Equal(t, ErrInvalidFile, err)
+}
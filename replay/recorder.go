@@ -0,0 +1,126 @@
+package replay
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// Recorder records the events of a match in memory until it is saved
+type Recorder struct {
+	header Header
+	buf    *bytes.Buffer
+	writer *Writer
+	err    error
+	mutex  sync.Mutex
+}
+
+// NewRecorder starts recording a match
+func NewRecorder(header Header) *Recorder {
+	header.Version = Version
+	r := &Recorder{
+		header: header,
+		buf:    &bytes.Buffer{},
+	}
+	r.writer, r.err = NewWriter(r.buf, header)
+	return r
+}
+
+// Header of the recorded match
+func (r *Recorder) Header() Header {
+	return r.header
+}
+
+// Join of a Player at its current position
+func (r *Recorder) Join(tick uint32, p *model.Player) {
+	r.write(Event{
+		Kind:     Join,
+		Tick:     tick,
+		PlayerID: p.ID,
+		GlobalID: p.GlobalID,
+		Nickname: p.Nickname,
+		X:        p.Collider.Pivot.X,
+		Y:        p.Collider.Pivot.Y,
+	})
+}
+
+// Leave of a Player
+func (r *Recorder) Leave(tick uint32, p *model.Player) {
+	r.write(Event{
+		Kind:     Leave,
+		Tick:     tick,
+		PlayerID: p.ID,
+	})
+}
+
+// Input applied to a Player
+func (r *Recorder) Input(tick uint32, p *model.Player, controls model.Controls) {
+	r.write(Event{
+		Kind:     Input,
+		Tick:     tick,
+		PlayerID: p.ID,
+		Controls: controls,
+	})
+}
+
+// Spawn of a Player at its current position
+func (r *Recorder) Spawn(tick uint32, p *model.Player) {
+	r.write(Event{
+		Kind:     Spawn,
+		Tick:     tick,
+		PlayerID: p.ID,
+		X:        p.Collider.Pivot.X,
+		Y:        p.Collider.Pivot.Y,
+	})
+}
+
+// Keyframe of all Players
+func (r *Recorder) Keyframe(tick uint32, players []*model.Player) {
+	states := make([]PlayerState, 0, len(players))
+	for _, p := range players {
+		states = append(states, NewPlayerState(p))
+	}
+	r.write(Event{
+		Kind:    Keyframe,
+		Tick:    tick,
+		Players: states,
+	})
+}
+
+// End of the match
+func (r *Recorder) End(tick uint32) {
+	r.write(Event{
+		Kind: End,
+		Tick: tick,
+	})
+}
+
+func (r *Recorder) write(e Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.writer.Write(e)
+}
+
+// Save the replay to a file in dir, returns the path of the file
+func (r *Recorder) Save(dir string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return "", r.err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%s-%d.replay", r.header.Region, r.header.MapID, r.header.StartedAt.Unix())
+	path := filepath.Join(dir, name)
+	return path, ioutil.WriteFile(path, r.buf.Bytes(), 0644)
+}
@@ -0,0 +1,147 @@
+package replay
+
+import (
+	"fmt"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// Mismatch between a recorded keyframe and the re-simulated state
+type Mismatch struct {
+	Tick      uint32
+	PlayerID  int
+	Recorded  PlayerState
+	Simulated PlayerState
+	Missing   bool
+}
+
+func (m Mismatch) String() string {
+	if m.Missing {
+		return fmt.Sprintf("tick %d: Player %d is missing in the simulation", m.Tick, m.PlayerID)
+	}
+	return fmt.Sprintf("tick %d: Player %d recorded %+v, simulated %+v", m.Tick, m.PlayerID, m.Recorded, m.Simulated)
+}
+
+// Simulator re-simulates a recorded match with the game model
+type Simulator struct {
+	Header     Header
+	State      *model.GameState
+	Mismatches []Mismatch
+	Ended      bool
+
+	players map[int]*model.Player
+	// Joins and Leaves take effect once the events of their tick are applied
+	pending []Event
+	tick    uint32
+	// snapshot of the Players at the start of the current tick, like the server iterates them
+	snapshot []*model.Player
+}
+
+// NewSimulator creates a Simulator for a replay header
+func NewSimulator(header Header) (*Simulator, error) {
+	state := model.NewGameState(header.Region)
+	if state.Map.ID != header.MapID {
+		return nil, fmt.Errorf("replay: map %s is not available", header.MapID)
+	}
+	return &Simulator{
+		Header:  header,
+		State:   state,
+		players: make(map[int]*model.Player),
+	}, nil
+}
+
+// Apply the next event of the replay
+func (s *Simulator) Apply(e Event) error {
+	if e.Tick != s.tick {
+		s.applyPending()
+		s.tick = e.Tick
+		s.snapshot = nil
+	}
+
+	switch e.Kind {
+	case Join:
+		player := model.NewPlayer(e.PlayerID, e.X, e.Y, nil)
+		player.GlobalID = e.GlobalID
+		player.Nickname = e.Nickname
+		s.players[player.ID] = player
+		s.pending = append(s.pending, e)
+	case Leave:
+		if _, err := s.player(e); err != nil {
+			return err
+		}
+		s.pending = append(s.pending, e)
+	case Input:
+		player, err := s.player(e)
+		if err != nil {
+			return err
+		}
+		if s.snapshot == nil {
+			s.snapshot = s.State.GetPlayers()
+		}
+		player.Control = e.Controls
+		player.Update(s.snapshot, s.State, s.Header.Timestep)
+	case Spawn:
+		player, err := s.player(e)
+		if err != nil {
+			return err
+		}
+		player.Respawn(&model.Point{X: e.X, Y: e.Y})
+	case Keyframe:
+		s.verify(e)
+	case End:
+		s.applyPending()
+		s.Ended = true
+	}
+	return nil
+}
+
+func (s *Simulator) applyPending() {
+	for _, e := range s.pending {
+		player := s.players[e.PlayerID]
+		switch e.Kind {
+		case Join:
+			s.State.AddPlayer(player)
+		case Leave:
+			delete(s.players, e.PlayerID)
+			s.State.RemovePlayer(player)
+		}
+	}
+	s.pending = s.pending[:0]
+}
+
+// Players of the simulation
+func (s *Simulator) Players() []*model.Player {
+	return s.State.GetPlayers()
+}
+
+func (s *Simulator) player(e Event) (*model.Player, error) {
+	player, ok := s.players[e.PlayerID]
+	if !ok {
+		return nil, fmt.Errorf("replay: tick %d: unknown Player %d", e.Tick, e.PlayerID)
+	}
+	return player, nil
+}
+
+func (s *Simulator) verify(e Event) {
+	for _, recorded := range e.Players {
+		player, ok := s.players[recorded.ID]
+		if !ok {
+			s.Mismatches = append(s.Mismatches, Mismatch{
+				Tick:     e.Tick,
+				PlayerID: recorded.ID,
+				Recorded: recorded,
+				Missing:  true,
+			})
+			continue
+		}
+		simulated := NewPlayerState(player)
+		if simulated != recorded {
+			s.Mismatches = append(s.Mismatches, Mismatch{
+				Tick:      e.Tick,
+				PlayerID:  recorded.ID,
+				Recorded:  recorded,
+				Simulated: simulated,
+			})
+		}
+	}
+}
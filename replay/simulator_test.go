@@ -0,0 +1,64 @@
+package replay
+
+import (
+	"testing"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulatorReproducesRecording(t *testing.T) {
+	header := Header{MapID: "default", Region: "EU", Tickrate: 30, Timestep: 0.033, KeyframeInterval: 10}
+	recorder := NewRecorder(header)
+
+	state := model.NewGameState(header.Region)
+	player := model.NewPlayer(1, 20, 20, nil)
+	state.AddPlayer(player)
+	recorder.Join(0, player)
+
+	for tick := uint32(1); tick <= 30; tick++ {
+		players := state.GetPlayers()
+		player.Control = model.Controls{Forward: true, Left: tick < 15, TurretRight: true}
+		recorder.Input(tick, player, player.Control)
+		player.Update(players, state, header.Timestep)
+		if tick%uint32(header.KeyframeInterval) == 0 {
+			recorder.Keyframe(tick, players)
+		}
+	}
+	recorder.End(31)
+
+	reader, err := NewReader(recorder.buf)
+	assert.Nil(t, err)
+	simulator, err := NewSimulator(reader.Header)
+	assert.Nil(t, err)
+	for !simulator.Ended {
+		e, err := reader.Next()
+		assert.Nil(t, err)
+		assert.Nil(t, simulator.Apply(e))
+	}
+	assert.Empty(t, simulator.Mismatches)
+	assert.Equal(t, NewPlayerState(player), NewPlayerState(simulator.Players()[0]))
+}
+
+func TestSimulatorReportsMismatch(t *testing.T) {
+	simulator, err := NewSimulator(Header{MapID: "default", Region: "EU", Timestep: 0.033})
+	assert.Nil(t, err)
+	assert.Nil(t, simulator.Apply(Event{Kind: Join, Tick: 0, PlayerID: 1, X: 20, Y: 20}))
+	assert.Nil(t, simulator.Apply(Event{Kind: Input, Tick: 1, PlayerID: 1, Controls: model.Controls{Forward: true}}))
+	assert.Nil(t, simulator.Apply(Event{Kind: Keyframe, Tick: 1, Players: []PlayerState{{ID: 1, X: 20, Y: 20, Health: 100}, {ID: 2}}}))
+
+	assert.Len(t, simulator.Mismatches, 2)
+	assert.False(t, simulator.Mismatches[0].Missing)
+	assert.True(t, simulator.Mismatches[1].Missing)
+}
+
+func TestSimulatorUnknownMap(t *testing.T) {
+	_, err := NewSimulator(Header{MapID: "unknown"})
+	assert.NotNil(t, err)
+}
+
+func TestSimulatorUnknownPlayer(t *testing.T) {
+	simulator, err := NewSimulator(Header{MapID: "default"})
+	assert.Nil(t, err)
+	assert.NotNil(t, simulator.Apply(Event{Kind: Input, Tick: 1, PlayerID: 3}))
+}
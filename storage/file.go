@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File keeps everything in memory and writes it to a JSON file after every change,
+// for single servers without a database
+type File struct {
+	*Memory
+	path string
+	// saving serializes writes, so the file always ends up with the latest data
+	saving sync.Mutex
+}
+
+// NewFile loads the store from the file at path, it is created on the first write
+func NewFile(path string) (*File, error) {
+	f := &File{
+		Memory: NewMemory(),
+		path:   path,
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.Memory.data); err != nil {
+		return nil, err
+	}
+	if f.Memory.data.Profiles == nil {
+		f.Memory.data.Profiles = make(map[string]*Profile)
+	}
+	if f.Memory.data.Servers == nil {
+		f.Memory.data.Servers = make(map[string]*ServerState)
+	}
+	if f.Memory.data.Stats == nil {
+		f.Memory.data.Stats = make(map[string]*LifetimeStats)
+	}
+	if f.Memory.data.Ratings == nil {
+		f.Memory.data.Ratings = make(map[string]*Rating)
+	}
+	return f, nil
+}
+
+// SaveProfile ...
+func (f *File) SaveProfile(profile *Profile) error {
+	f.Memory.SaveProfile(profile)
+	return f.save()
+}
+
+// AddHighscores ...
+func (f *File) AddHighscores(highscores []Highscore) error {
+	f.Memory.AddHighscores(highscores)
+	return f.save()
+}
+
+// SaveServerState ...
+func (f *File) SaveServerState(state *ServerState) error {
+	f.Memory.SaveServerState(state)
+	return f.save()
+}
+
+// AddStats ...
+func (f *File) AddStats(match LifetimeStats) error {
+	f.Memory.AddStats(match)
+	return f.save()
+}
+
+// SaveRatings ...
+func (f *File) SaveRatings(ratings []Rating) error {
+	f.Memory.SaveRatings(ratings)
+	return f.save()
+}
+
+// save the store to a temporary file first, so a crash never leaves a broken file behind
+func (f *File) save() error {
+	f.saving.Lock()
+	defer f.saving.Unlock()
+	f.Memory.mutex.RLock()
+	data, err := json.MarshalIndent(f.Memory.data, "", "  ")
+	f.Memory.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// collections of the Firestore store
+const (
+	profilesCollection   = "profiles"
+	highscoresCollection = "highscores"
+	serversCollection    = "servers"
+	statsCollection      = "stats"
+	ratingsCollection    = "ratings"
+)
+
+// firestoreTimeout of a single request
+const firestoreTimeout = 5 * time.Second
+
+// Firestore keeps everything in Google Cloud Firestore
+type Firestore struct {
+	client *firestore.Client
+}
+
+// NewFirestore ...
+func NewFirestore(client *firestore.Client) *Firestore {
+	return &Firestore{
+		client: client,
+	}
+}
+
+// Profile ...
+func (f *Firestore) Profile(globalID string) (*Profile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), firestoreTimeout)
+	defer cancel()
+	doc, err := f.client.Collection(profilesCollection).Doc(globalID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	profile := &Profile{}
+	if err := doc.DataTo(profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// SaveProfile ...
+func (f *Firestore) SaveProfile(profile *Profile) error {
+	ctx, cancel := context.WithTimeout(context.Background(), firestoreTimeout)
+	defer cancel()
+	_, err := f.client.Collection(profilesCollection).Doc(profile.GlobalID).Set(ctx, profile)
+	return err
+}
+
+// AddHighscores ...
+func (f *Firestore) AddHighscores(highscores []Highscore) error {
+	if len(highscores) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), firestoreTimeout)
+	defer cancel()
+	batch := f.client.Batch()
+	for i := range highscores {
+		batch.Create(f.client.Collection(highscoresCollection).NewDoc(), &highscores[i])
+	}
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+// Highscores ...
+func (f *Firestore) Highscores(limit int) ([]Highscore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), firestoreTimeout)
+	defer cancel()
+	query := f.client.Collection(highscoresCollection).OrderBy("score", firestore.Desc)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	highscores := make([]Highscore, 0, len(docs))
+	for _, doc := range docs {
+		var highscore Highscore
+		if err := doc.DataTo(&highscore); err != nil {
+			return nil, err
+		}
+		highscores = append(highscores, highscore)
+	}
+	return highscores, nil
+}
+
+// SaveServerState ...
+func (f *Firestore) SaveServerState(state *ServerState) error {
+	ctx, cancel := context.WithTimeout(context.Background(), firestoreTimeout)
+	defer cancel()
+	_, err := f.client.Collection(serversCollection).Doc(state.ID).Set(ctx, state)
+	return err
+}
+
+// AddStats in a transaction, so concurrent matches of a player do not overwrite each other
+func (f *Firestore) AddStats(match LifetimeStats) error {
+	ctx, cancel := context.WithTimeout(context.Background(), firestoreTimeout)
+	defer cancel()
+	ref := f.client.Collection(statsCollection).Doc(match.GlobalID)
+	return f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		stats := &LifetimeStats{}
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(stats); err != nil {
+				return err
+			}
+		}
+		stats.Add(match)
+		return tx.Set(ref, stats)
+	})
+}
+
+// Stats ...
+func (f *Firestore) Stats(globalID string) (*LifetimeStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), firestoreTimeout)
+	defer cancel()
+	doc, err := f.client.Collection(statsCollection).Doc(globalID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	stats := &LifetimeStats{}
+	if err := doc.DataTo(stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Leaderboard ...
+func (f *Firestore) Leaderboard(by Ranking, limit int) ([]LifetimeStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), firestoreTimeout)
+	defer cancel()
+	query := f.client.Collection(statsCollection).OrderBy(string(by), firestore.Desc)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	leaderboard := make([]LifetimeStats, 0, len(docs))
+	for _, doc := range docs {
+		var stats LifetimeStats
+		if err := doc.DataTo(&stats); err != nil {
+			return nil, err
+		}
+		leaderboard = append(leaderboard, stats)
+	}
+	return leaderboard, nil
+}
+
+// Ratings ...
+func (f *Firestore) Ratings(globalIDs []string) (map[string]Rating, error) {
+	ratings := make(map[string]Rating, len(globalIDs))
+	if len(globalIDs) == 0 {
+		return ratings, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), firestoreTimeout)
+	defer cancel()
+	refs := make([]*firestore.DocumentRef, 0, len(globalIDs))
+	for _, globalID := range globalIDs {
+		refs = append(refs, f.client.Collection(ratingsCollection).Doc(globalID))
+	}
+	docs, err := f.client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var rating Rating
+		if err := doc.DataTo(&rating); err != nil {
+			return nil, err
+		}
+		ratings[rating.GlobalID] = rating
+	}
+	return ratings, nil
+}
+
+// SaveRatings of a match in a single batch, so either all ratings of the match change or none
+func (f *Firestore) SaveRatings(ratings []Rating) error {
+	if len(ratings) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), firestoreTimeout)
+	defer cancel()
+	batch := f.client.Batch()
+	for i := range ratings {
+		batch.Set(f.client.Collection(ratingsCollection).Doc(ratings[i].GlobalID), &ratings[i])
+	}
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+// Close ...
+func (f *Firestore) Close() error {
+	return f.client.Close()
+}
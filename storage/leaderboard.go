@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultLeaderboardSize = 10
+	maxLeaderboardSize     = 100
+)
+
+// LeaderboardHandler serves the leaderboard as JSON, ordered by the ranking of the "by" query param
+// with up to "limit" entries, eg. /leaderboard?by=wins&limit=20
+func LeaderboardHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		by, err := ParseRanking(r.URL.Query().Get("by"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit := defaultLeaderboardSize
+		if param := r.URL.Query().Get("limit"); param != "" {
+			limit, err = strconv.Atoi(param)
+			if err != nil || limit < 1 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		if limit > maxLeaderboardSize {
+			limit = maxLeaderboardSize
+		}
+
+		leaderboard, err := store.Leaderboard(by, limit)
+		if err != nil {
+			log.Printf("Storage: Could not load leaderboard: %s", err)
+			http.Error(w, "leaderboard unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(leaderboard)
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"sync"
+)
+
+// Memory keeps everything in memory, it is lost on restart
+type Memory struct {
+	data  memoryData
+	mutex sync.RWMutex
+}
+
+// memoryData is everything a Memory store keeps, the file store persists it as JSON
+type memoryData struct {
+	Profiles   map[string]*Profile       `json:"profiles"`
+	Highscores []Highscore               `json:"highscores"`
+	Servers    map[string]*ServerState   `json:"servers"`
+	Stats      map[string]*LifetimeStats `json:"stats"`
+	Ratings    map[string]*Rating        `json:"ratings"`
+}
+
+// NewMemory ...
+func NewMemory() *Memory {
+	return &Memory{
+		data: memoryData{
+			Profiles: make(map[string]*Profile),
+			Servers:  make(map[string]*ServerState),
+			Stats:    make(map[string]*LifetimeStats),
+			Ratings:  make(map[string]*Rating),
+		},
+	}
+}
+
+// Profile ...
+func (m *Memory) Profile(globalID string) (*Profile, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	profile, ok := m.data.Profiles[globalID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *profile
+	return &copied, nil
+}
+
+// SaveProfile ...
+func (m *Memory) SaveProfile(profile *Profile) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	copied := *profile
+	m.data.Profiles[profile.GlobalID] = &copied
+	return nil
+}
+
+// AddHighscores keeps the best maxHighscores
+func (m *Memory) AddHighscores(highscores []Highscore) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data.Highscores = append(m.data.Highscores, highscores...)
+	sortHighscores(m.data.Highscores)
+	if len(m.data.Highscores) > maxHighscores {
+		m.data.Highscores = m.data.Highscores[:maxHighscores]
+	}
+	return nil
+}
+
+// Highscores ...
+func (m *Memory) Highscores(limit int) ([]Highscore, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if limit <= 0 || limit > len(m.data.Highscores) {
+		limit = len(m.data.Highscores)
+	}
+	highscores := make([]Highscore, limit)
+	copy(highscores, m.data.Highscores)
+	return highscores, nil
+}
+
+// SaveServerState ...
+func (m *Memory) SaveServerState(state *ServerState) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	copied := *state
+	m.data.Servers[state.ID] = &copied
+	return nil
+}
+
+// AddStats ...
+func (m *Memory) AddStats(match LifetimeStats) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	stats, ok := m.data.Stats[match.GlobalID]
+	if !ok {
+		stats = &LifetimeStats{}
+		m.data.Stats[match.GlobalID] = stats
+	}
+	stats.Add(match)
+	return nil
+}
+
+// Stats ...
+func (m *Memory) Stats(globalID string) (*LifetimeStats, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	stats, ok := m.data.Stats[globalID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *stats
+	return &copied, nil
+}
+
+// Leaderboard ...
+func (m *Memory) Leaderboard(by Ranking, limit int) ([]LifetimeStats, error) {
+	m.mutex.RLock()
+	leaderboard := make([]LifetimeStats, 0, len(m.data.Stats))
+	for _, stats := range m.data.Stats {
+		leaderboard = append(leaderboard, *stats)
+	}
+	m.mutex.RUnlock()
+
+	sortLeaderboard(leaderboard, by)
+	if limit > 0 && limit < len(leaderboard) {
+		leaderboard = leaderboard[:limit]
+	}
+	return leaderboard, nil
+}
+
+// Ratings ...
+func (m *Memory) Ratings(globalIDs []string) (map[string]Rating, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	ratings := make(map[string]Rating, len(globalIDs))
+	for _, globalID := range globalIDs {
+		if rating, ok := m.data.Ratings[globalID]; ok {
+			ratings[globalID] = *rating
+		}
+	}
+	return ratings, nil
+}
+
+// SaveRatings ...
+func (m *Memory) SaveRatings(ratings []Rating) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, rating := range ratings {
+		copied := rating
+		m.data.Ratings[rating.GlobalID] = &copied
+	}
+	return nil
+}
+
+// Close ...
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,12 @@
+package storage
+
+import "time"
+
+// Rating of the skill of a player, updated after every match
+type Rating struct {
+	GlobalID  string    `json:"global_id" firestore:"global_id"`
+	Nickname  string    `json:"nickname" firestore:"nickname"`
+	Rating    float64   `json:"rating" firestore:"rating"`
+	Matches   int       `json:"matches" firestore:"matches"`
+	UpdatedAt time.Time `json:"updated_at" firestore:"updated_at"`
+}
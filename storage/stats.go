@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// LifetimeStats of a player summed up over all matches
+type LifetimeStats struct {
+	GlobalID   string `json:"global_id" firestore:"global_id"`
+	Nickname   string `json:"nickname" firestore:"nickname"`
+	Kills      int    `json:"kills" firestore:"kills"`
+	Deaths     int    `json:"deaths" firestore:"deaths"`
+	ShotsFired int    `json:"shots_fired" firestore:"shots_fired"`
+	Hits       int    `json:"hits" firestore:"hits"`
+	Assists    int    `json:"assists" firestore:"assists"`
+	// DamageDealt to other players
+	DamageDealt int           `json:"damage_dealt" firestore:"damage_dealt"`
+	Matches     int           `json:"matches" firestore:"matches"`
+	Wins        int           `json:"wins" firestore:"wins"`
+	PlayTime    time.Duration `json:"play_time" firestore:"play_time"`
+	// Accuracy is the share of shots that hit, kept up to date by Add so leaderboards can be ordered by it
+	Accuracy  float64   `json:"accuracy" firestore:"accuracy"`
+	UpdatedAt time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// Add the stats of a match, the nickname is taken from the match
+func (s *LifetimeStats) Add(match LifetimeStats) {
+	s.GlobalID = match.GlobalID
+	if match.Nickname != "" {
+		s.Nickname = match.Nickname
+	}
+	s.Kills += match.Kills
+	s.Deaths += match.Deaths
+	s.ShotsFired += match.ShotsFired
+	s.Hits += match.Hits
+	s.Assists += match.Assists
+	s.DamageDealt += match.DamageDealt
+	s.Matches += match.Matches
+	s.Wins += match.Wins
+	s.PlayTime += match.PlayTime
+	s.Accuracy = 0
+	if s.ShotsFired > 0 {
+		s.Accuracy = float64(s.Hits) / float64(s.ShotsFired)
+	}
+	s.UpdatedAt = match.UpdatedAt
+}
+
+// Ranking a leaderboard is ordered by, the name of the field
+type Ranking string
+
+// Rankings of the leaderboard
+const (
+	RankKills    Ranking = "kills"
+	RankWins     Ranking = "wins"
+	RankAccuracy Ranking = "accuracy"
+	RankMatches  Ranking = "matches"
+	RankPlayTime Ranking = "play_time"
+)
+
+// ParseRanking from its name, kills if empty
+func ParseRanking(name string) (Ranking, error) {
+	switch r := Ranking(name); r {
+	case "":
+		return RankKills, nil
+	case RankKills, RankWins, RankAccuracy, RankMatches, RankPlayTime:
+		return r, nil
+	}
+	return RankKills, fmt.Errorf("storage: unknown ranking %s", name)
+}
+
+func (r Ranking) value(s *LifetimeStats) float64 {
+	switch r {
+	case RankWins:
+		return float64(s.Wins)
+	case RankAccuracy:
+		return s.Accuracy
+	case RankMatches:
+		return float64(s.Matches)
+	case RankPlayTime:
+		return float64(s.PlayTime)
+	}
+	return float64(s.Kills)
+}
+
+// sortLeaderboard best first, ties by GlobalID so the order is stable
+func sortLeaderboard(stats []LifetimeStats, by Ranking) {
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := by.value(&stats[i]), by.value(&stats[j])
+		if a != b {
+			return a > b
+		}
+		return stats[i].GlobalID < stats[j].GlobalID
+	})
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awdng/triebwerk"
+)
+
+// Backends selectable with the STORAGE config
+const (
+	BackendMemory    = "memory"
+	BackendFile      = "file"
+	BackendFirestore = "firestore"
+)
+
+// ErrNotFound is returned if a record does not exist
+var ErrNotFound = errors.New("storage: not found")
+
+// Profile of an authenticated player
+type Profile struct {
+	GlobalID  string    `json:"global_id" firestore:"global_id"`
+	Nickname  string    `json:"nickname" firestore:"nickname"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+	LastSeen  time.Time `json:"last_seen" firestore:"last_seen"`
+}
+
+// Highscore of a player in a single match
+type Highscore struct {
+	GlobalID string    `json:"global_id" firestore:"global_id"`
+	Nickname string    `json:"nickname" firestore:"nickname"`
+	Score    int       `json:"score" firestore:"score"`
+	At       time.Time `json:"at" firestore:"at"`
+}
+
+// ServerState of a game server, scores, names and ratings are keyed by the GlobalID of the players
+type ServerState struct {
+	ID        string             `json:"id" firestore:"id"`
+	Address   string             `json:"address" firestore:"public_ip"`
+	Region    string             `json:"region" firestore:"region"`
+	Scores    map[string]int     `json:"scores" firestore:"scores"`
+	Names     map[string]string  `json:"names" firestore:"names"`
+	Ratings   map[string]float64 `json:"ratings" firestore:"ratings"`
+	GameTime  int                `json:"game_time" firestore:"gametime"`
+	UpdatedAt time.Time          `json:"updated_at" firestore:"updated_at"`
+}
+
+// Store persists player profiles, lifetime stats, highscores and server states
+type Store interface {
+	// Profile of a player, ErrNotFound if it does not exist
+	Profile(globalID string) (*Profile, error)
+	SaveProfile(profile *Profile) error
+	// AddHighscores of a finished match in one write
+	AddHighscores(highscores []Highscore) error
+	// Highscores returns the best scores, highest first
+	Highscores(limit int) ([]Highscore, error)
+	SaveServerState(state *ServerState) error
+	// AddStats of a match to the lifetime stats of the player
+	AddStats(match LifetimeStats) error
+	// Stats of a player, ErrNotFound if it never finished a match
+	Stats(globalID string) (*LifetimeStats, error)
+	// Leaderboard returns the lifetime stats of the best players, best first
+	Leaderboard(by Ranking, limit int) ([]LifetimeStats, error)
+	// Ratings of the players, players without a rating are missing
+	Ratings(globalIDs []string) (map[string]Rating, error)
+	SaveRatings(ratings []Rating) error
+	Close() error
+}
+
+// New creates the Store selected by the config
+func New(config triebwerk.Config, firebase *triebwerk.Firebase) (Store, error) {
+	switch strings.ToLower(config.Storage) {
+	case BackendMemory, "":
+		return NewMemory(), nil
+	case BackendFile:
+		return NewFile(config.StoragePath)
+	case BackendFirestore:
+		if firebase == nil || firebase.Store == nil {
+			return nil, errors.New("storage: firestore backend needs a firestore client")
+		}
+		return NewFirestore(firebase.Store), nil
+	}
+	return nil, fmt.Errorf("storage: unknown backend %s", config.Storage)
+}
+
+// maxHighscores kept by the memory and file stores, lower scores are dropped
+const maxHighscores = 100
+
+// sortHighscores highest first, earlier scores win ties
+func sortHighscores(highscores []Highscore) {
+	sort.SliceStable(highscores, func(i, j int) bool {
+		if highscores[i].Score != highscores[j].Score {
+			return highscores[i].Score > highscores[j].Score
+		}
+		return highscores[i].At.Before(highscores[j].At)
+	})
+}
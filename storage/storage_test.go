@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awdng/triebwerk"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStore runs the same checks against every implementation
+func testStore(t *testing.T, store Store) {
+	_, err := store.Profile("alice")
+	assert.Equal(t, ErrNotFound, err)
+
+	created := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.Nil(t, store.SaveProfile(&Profile{GlobalID: "alice", Nickname: "Alice", CreatedAt: created, LastSeen: created}))
+	profile, err := store.Profile("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, "Alice", profile.Nickname)
+	assert.True(t, created.Equal(profile.CreatedAt))
+
+	assert.Nil(t, store.AddHighscores([]Highscore{
+		{GlobalID: "alice", Score: 5, At: created},
+		{GlobalID: "bob", Score: 9, At: created},
+	}))
+	assert.Nil(t, store.AddHighscores([]Highscore{{GlobalID: "carol", Score: 5, At: created.Add(-time.Hour)}}))
+	highscores, err := store.Highscores(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(highscores))
+	assert.Equal(t, "bob", highscores[0].GlobalID)
+	assert.Equal(t, "carol", highscores[1].GlobalID)
+
+	assert.Nil(t, store.SaveServerState(&ServerState{ID: "localhost:80", Scores: map[string]int{"alice": 5}}))
+
+	_, err = store.Stats("alice")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Nil(t, store.AddStats(LifetimeStats{GlobalID: "alice", Nickname: "Alice", Kills: 3, ShotsFired: 10, Hits: 5, Matches: 1, PlayTime: time.Minute}))
+	assert.Nil(t, store.AddStats(LifetimeStats{GlobalID: "alice", Nickname: "Alice B.", Kills: 1, ShotsFired: 10, Hits: 1, Matches: 1, Wins: 1, PlayTime: time.Minute}))
+	assert.Nil(t, store.AddStats(LifetimeStats{GlobalID: "bob", Kills: 2, ShotsFired: 2, Hits: 2, Matches: 1}))
+	stats, err := store.Stats("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, "Alice B.", stats.Nickname)
+	assert.Equal(t, 4, stats.Kills)
+	assert.Equal(t, 2, stats.Matches)
+	assert.Equal(t, 1, stats.Wins)
+	assert.Equal(t, 0.3, stats.Accuracy)
+	assert.Equal(t, 2*time.Minute, stats.PlayTime)
+
+	leaderboard, err := store.Leaderboard(RankKills, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, globalIDs(leaderboard))
+	leaderboard, err = store.Leaderboard(RankAccuracy, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bob"}, globalIDs(leaderboard))
+
+	assert.Nil(t, store.SaveRatings([]Rating{{GlobalID: "alice", Rating: 1516, Matches: 1}, {GlobalID: "bob", Rating: 1484, Matches: 1}}))
+	assert.Nil(t, store.SaveRatings([]Rating{{GlobalID: "alice", Rating: 1530, Matches: 2}}))
+	ratings, err := store.Ratings([]string{"alice", "bob", "carol"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(ratings))
+	assert.Equal(t, 1530.0, ratings["alice"].Rating)
+	assert.Equal(t, 2, ratings["alice"].Matches)
+	assert.Equal(t, 1484.0, ratings["bob"].Rating)
+}
+
+func globalIDs(leaderboard []LifetimeStats) []string {
+	ids := []string{}
+	for _, stats := range leaderboard {
+		ids = append(ids, stats.GlobalID)
+	}
+	return ids
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestOnlyTheBestHighscoresAreKept(t *testing.T) {
+	store := NewMemory()
+	highscores := []Highscore{}
+	for score := 1; score <= maxHighscores+10; score++ {
+		highscores = append(highscores, Highscore{Score: score})
+	}
+	assert.Nil(t, store.AddHighscores(highscores))
+
+	kept, err := store.Highscores(0)
+	assert.Nil(t, err)
+	assert.Equal(t, maxHighscores, len(kept))
+	assert.Equal(t, maxHighscores+10, kept[0].Score)
+	assert.Equal(t, 11, kept[maxHighscores-1].Score)
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "triebwerk.json")
+
+	store, err := NewFile(path)
+	assert.Nil(t, err)
+	testStore(t, store)
+
+	// everything is still there after a restart
+	reloaded, err := NewFile(path)
+	assert.Nil(t, err)
+	profile, err := reloaded.Profile("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, "Alice", profile.Nickname)
+	highscores, err := reloaded.Highscores(0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(highscores))
+	stats, err := reloaded.Stats("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, stats.Kills)
+	ratings, err := reloaded.Ratings([]string{"alice"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1530.0, ratings["alice"].Rating)
+}
+
+func TestNew(t *testing.T) {
+	store, err := New(triebwerk.Config{}, nil)
+	assert.Nil(t, err)
+	assert.IsType(t, &Memory{}, store)
+
+	_, err = New(triebwerk.Config{Storage: BackendFirestore}, nil)
+	assert.NotNil(t, err)
+	_, err = New(triebwerk.Config{Storage: "postgres"}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestLeaderboardHandler(t *testing.T) {
+	store := NewMemory()
+	for i, id := range []string{"alice", "bob", "carol"} {
+		store.AddStats(LifetimeStats{GlobalID: id, Kills: i, Wins: 2 - i})
+	}
+	handler := LeaderboardHandler(store)
+
+	get := func(url string) (int, []LifetimeStats) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		leaderboard := []LifetimeStats{}
+		if recorder.Code == http.StatusOK {
+			assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &leaderboard))
+		}
+		return recorder.Code, leaderboard
+	}
+
+	code, leaderboard := get("/leaderboard")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"carol", "bob", "alice"}, globalIDs(leaderboard))
+	code, leaderboard = get("/leaderboard?by=wins&limit=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"alice"}, globalIDs(leaderboard))
+	code, _ = get("/leaderboard?by=deaths")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("/leaderboard?limit=x")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,305 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// bufferSize of messages in flight per direction before writes block
+const bufferSize = 1024
+
+var (
+	// ErrClosed is returned when reading or writing a closed connection
+	ErrClosed = errors.New("memory: connection closed")
+
+	// ErrMessageSize is returned by Read if a message exceeds the read limit
+	ErrMessageSize = errors.New("memory: message exceeds read limit")
+)
+
+// Options simulate network conditions, the zero value is a perfect network
+type Options struct {
+	// Latency added to every message
+	Latency time.Duration
+	// Jitter is a random additional latency up to the given duration, message order is preserved
+	Jitter time.Duration
+	// Loss is the probability between 0 and 1 that a message is dropped
+	Loss float64
+	// Seed of the random source for jitter and loss, the same seed produces the same conditions
+	Seed int64
+}
+
+// Transport is an in-process network context, connections are opened with Dial
+type Transport struct {
+	register   func(conn model.Connection)
+	unregister func(conn model.Connection)
+	options    Options
+
+	mutex  sync.Mutex
+	rng    *rand.Rand
+	nextID int
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewTransport creates an in-memory transport
+func NewTransport(options Options) *Transport {
+	return &Transport{
+		options: options,
+		rng:     rand.New(rand.NewSource(options.Seed)),
+		closed:  make(chan struct{}),
+	}
+}
+
+// GetAddress ...
+func (t *Transport) GetAddress() string {
+	return "memory"
+}
+
+// RegisterNewConnHandler is a callback for new connections
+func (t *Transport) RegisterNewConnHandler(register func(conn model.Connection)) {
+	t.register = register
+}
+
+// UnregisterConnHandler is a callback for closed connections
+func (t *Transport) UnregisterConnHandler(unregister func(conn model.Connection)) {
+	t.unregister = unregister
+}
+
+// Unregister callback
+func (t *Transport) Unregister(conn model.Connection) {
+	t.unregister(conn)
+}
+
+// Init ...
+func (t *Transport) Init() {}
+
+// Run blocks until the transport is closed
+func (t *Transport) Run() error {
+	<-t.closed
+	return nil
+}
+
+// Close the transport, Run returns
+func (t *Transport) Close() {
+	t.once.Do(func() {
+		close(t.closed)
+	})
+}
+
+// Dial opens a connection with the given params, the server side is passed to the registered
+// connection handler and the client side is returned
+func (t *Transport) Dial(params url.Values) *Connection {
+	t.mutex.Lock()
+	t.nextID++
+	id := t.nextID
+	t.mutex.Unlock()
+
+	closed := make(chan struct{})
+	once := &sync.Once{}
+	toServer := t.newLink(closed)
+	toClient := t.newLink(closed)
+
+	server := &Connection{
+		identifier: fmt.Sprintf("memory - server %d", id),
+		in:         toServer,
+		out:        toClient,
+		closed:     closed,
+		once:       once,
+		params:     params,
+	}
+	client := &Connection{
+		identifier: fmt.Sprintf("memory - client %d", id),
+		in:         toClient,
+		out:        toServer,
+		closed:     closed,
+		once:       once,
+		params:     params,
+	}
+
+	t.register(server)
+	return client
+}
+
+func (t *Transport) newLink(closed chan struct{}) *link {
+	l := &link{
+		transport: t,
+		inbox:     make(chan []byte, bufferSize),
+		closed:    closed,
+	}
+	if t.options.Latency > 0 || t.options.Jitter > 0 {
+		l.delayed = make(chan delayedMessage, bufferSize)
+		go l.deliverDelayed()
+	}
+	return l
+}
+
+// drop decides if a message is lost
+func (t *Transport) drop() bool {
+	if t.options.Loss <= 0 {
+		return false
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.rng.Float64() < t.options.Loss
+}
+
+// delay of a message
+func (t *Transport) delay() time.Duration {
+	delay := t.options.Latency
+	if t.options.Jitter > 0 {
+		t.mutex.Lock()
+		delay += time.Duration(t.rng.Int63n(int64(t.options.Jitter)))
+		t.mutex.Unlock()
+	}
+	return delay
+}
+
+type delayedMessage struct {
+	at   time.Time
+	data []byte
+}
+
+// link is one direction of a connection
+type link struct {
+	transport *Transport
+	inbox     chan []byte
+	delayed   chan delayedMessage
+	closed    chan struct{}
+
+	mutex sync.Mutex
+	last  time.Time
+}
+
+func (l *link) send(data []byte) error {
+	if l.transport.drop() {
+		return nil
+	}
+	message := make([]byte, len(data))
+	copy(message, data)
+
+	if l.delayed == nil {
+		select {
+		case l.inbox <- message:
+			return nil
+		case <-l.closed:
+			return ErrClosed
+		}
+	}
+
+	// jitter must not reorder messages, like a stream socket
+	l.mutex.Lock()
+	at := time.Now().Add(l.transport.delay())
+	if at.Before(l.last) {
+		at = l.last
+	}
+	l.last = at
+	l.mutex.Unlock()
+
+	select {
+	case l.delayed <- delayedMessage{at: at, data: message}:
+		return nil
+	case <-l.closed:
+		return ErrClosed
+	}
+}
+
+func (l *link) deliverDelayed() {
+	for {
+		select {
+		case message := <-l.delayed:
+			timer := time.NewTimer(time.Until(message.at))
+			select {
+			case <-timer.C:
+			case <-l.closed:
+				timer.Stop()
+				return
+			}
+			select {
+			case l.inbox <- message.data:
+			case <-l.closed:
+				return
+			}
+		case <-l.closed:
+			return
+		}
+	}
+}
+
+// Connection is one end of an in-memory connection
+type Connection struct {
+	identifier     string
+	in             *link
+	out            *link
+	closed         chan struct{}
+	once           *sync.Once
+	maxMessageSize int64
+	params         url.Values
+}
+
+// Identifier of the connection
+func (c *Connection) Identifier() string {
+	return c.identifier
+}
+
+// Params the connection was dialed with
+func (c *Connection) Params() url.Values {
+	return c.params
+}
+
+// Ping is a noop, in-memory connections can not time out
+func (c *Connection) Ping(writeWait time.Duration) {}
+
+// Close both ends of the connection
+func (c *Connection) Close(writeWait time.Duration, graceful bool) {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+}
+
+// PrepareWrite is a noop
+func (c *Connection) PrepareWrite(writeWait time.Duration) {}
+
+// Write data to the other end of the connection
+func (c *Connection) Write(data []byte) error {
+	select {
+	case <-c.closed:
+		return ErrClosed
+	default:
+	}
+	return c.out.send(data)
+}
+
+// PrepareRead sets the read limit, the pong wait is ignored
+func (c *Connection) PrepareRead(maxMessageSize int64, pongWait time.Duration) {
+	c.maxMessageSize = maxMessageSize
+}
+
+// Read the next message, messages that arrived before the connection was closed are still returned
+func (c *Connection) Read() ([]byte, error) {
+	select {
+	case message := <-c.in.inbox:
+		return c.checkSize(message)
+	default:
+	}
+
+	select {
+	case message := <-c.in.inbox:
+		return c.checkSize(message)
+	case <-c.closed:
+		return nil, ErrClosed
+	}
+}
+
+func (c *Connection) checkSize(message []byte) ([]byte, error) {
+	if c.maxMessageSize > 0 && int64(len(message)) > c.maxMessageSize {
+		c.Close(0, false)
+		return nil, ErrMessageSize
+	}
+	return message, nil
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func dial(transport *Transport) (*Connection, model.Connection) {
+	var server model.Connection
+	transport.RegisterNewConnHandler(func(conn model.Connection) {
+		server = conn
+	})
+	client := transport.Dial(nil)
+	return client, server
+}
+
+func TestConnection(t *testing.T) {
+	client, server := dial(NewTransport(Options{}))
+
+	assert.Nil(t, client.Write([]byte{1, 2}))
+	message, err := server.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 2}, message)
+
+	assert.Nil(t, server.Write([]byte{3}))
+	server.Close(0, true)
+	// messages sent before closing are still delivered
+	message, err = client.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{3}, message)
+
+	_, err = client.Read()
+	assert.Equal(t, ErrClosed, err)
+	assert.Equal(t, ErrClosed, client.Write([]byte{4}))
+}
+
+func TestLatencyPreservesOrder(t *testing.T) {
+	client, server := dial(NewTransport(Options{
+		Latency: 10 * time.Millisecond,
+		Jitter:  20 * time.Millisecond,
+		Seed:    1,
+	}))
+	defer client.Close(0, false)
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		client.Write([]byte{byte(i)})
+	}
+	for i := 0; i < 10; i++ {
+		message, err := server.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{byte(i)}, message)
+	}
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+}
+
+func TestLossIsDeterministic(t *testing.T) {
+	received := func() []byte {
+		client, server := dial(NewTransport(Options{Loss: 0.5, Seed: 42}))
+		for i := 0; i < 20; i++ {
+			client.Write([]byte{byte(i)})
+		}
+		client.Close(0, false)
+
+		messages := make([]byte, 0)
+		for {
+			message, err := server.Read()
+			if err != nil {
+				return messages
+			}
+			messages = append(messages, message...)
+		}
+	}
+
+	first := received()
+	assert.True(t, len(first) > 0 && len(first) < 20)
+	assert.Equal(t, first, received())
+}
+
+func TestReadLimit(t *testing.T) {
+	client, server := dial(NewTransport(Options{}))
+	server.PrepareRead(2, time.Second)
+
+	client.Write([]byte{1, 2, 3})
+	_, err := server.Read()
+	assert.Equal(t, ErrMessageSize, err)
+}
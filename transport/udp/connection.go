@@ -0,0 +1,479 @@
+package udp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// resendInterval is the period in which unacknowledged reliable packets are checked
+	resendInterval = 50 * time.Millisecond
+
+	// resendTimeout after which an unacknowledged reliable packet is sent again
+	resendTimeout = 200 * time.Millisecond
+
+	// maxResends of a reliable packet before the connection is considered lost
+	maxResends = 25
+
+	// maxPending reliable packets waiting for acknowledgement
+	maxPending = 1024
+
+	// receiveWindow of reliable packets that are buffered out of order, packets beyond are dropped
+	// without ack and sent again. A peer never sends further ahead than its maxPending
+	receiveWindow = maxPending
+
+	// maxAssemblies of unreliable messages that are reassembled at the same time
+	maxAssemblies = 8
+
+	// incomingBufferSize of reassembled messages waiting to be read
+	incomingBufferSize = 256
+)
+
+var (
+	// ErrClosed is returned when reading or writing a closed connection
+	ErrClosed = errors.New("udp: connection closed")
+
+	// ErrTimeout is returned by Read if the peer did not send anything within the read deadline
+	ErrTimeout = errors.New("udp: connection timed out")
+
+	// ErrMessageSize is returned if a message does not fit into the maximum number of fragments
+	ErrMessageSize = errors.New("udp: message too large")
+
+	// ErrCongested is returned if too many reliable packets are waiting for acknowledgement
+	ErrCongested = errors.New("udp: too many unacknowledged packets")
+)
+
+type pendingPacket struct {
+	data    []byte
+	sentAt  time.Time
+	resends int
+}
+
+type assembly struct {
+	fragments [][]byte
+	received  int
+}
+
+// Connection represents a UDP session with a remote peer
+type Connection struct {
+	session uint32
+	addr    net.Addr
+	mtu     int
+	send    func(data []byte) error
+	onClose func(c *Connection)
+	params  url.Values
+
+	incoming  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mutex          sync.Mutex
+	lastReceived   time.Time
+	pongWait       time.Duration
+	maxMessageSize int64
+
+	// reliable ordered channel
+	reliableSequence uint16
+	pending          map[uint16]*pendingPacket
+	expected         uint16
+	received         map[uint16]*packet
+	fragments        [][]byte
+
+	// unreliable sequenced channel
+	unreliableSequence uint16
+	delivered          uint16
+	hasDelivered       bool
+	assemblies         map[uint16]*assembly
+}
+
+func newConnection(session uint32, addr net.Addr, mtu int, send func(data []byte) error) *Connection {
+	c := &Connection{
+		session:      session,
+		addr:         addr,
+		mtu:          mtu,
+		send:         send,
+		incoming:     make(chan []byte, incomingBufferSize),
+		closed:       make(chan struct{}),
+		lastReceived: time.Now(),
+		pending:      make(map[uint16]*pendingPacket),
+		received:     make(map[uint16]*packet),
+		assemblies:   make(map[uint16]*assembly),
+	}
+	go c.resend()
+	return c
+}
+
+// Identifier of the connection
+func (c *Connection) Identifier() string {
+	return fmt.Sprintf("%s - %s", c.addr.Network(), c.addr.String())
+}
+
+// Session ID of the connection
+func (c *Connection) Session() uint32 {
+	return c.session
+}
+
+// Params the client sent with its connect request
+func (c *Connection) Params() url.Values {
+	return c.params
+}
+
+// Ping sends a keepalive request the peer has to answer
+func (c *Connection) Ping(writeWait time.Duration) {
+	c.sendPacket(&packet{
+		kind:    packetKeepalive,
+		session: c.session,
+		payload: []byte{keepaliveRequest},
+	})
+}
+
+// Close the session, the peer is notified in both cases as UDP has no closing handshake
+func (c *Connection) Close(writeWait time.Duration, graceful bool) {
+	c.closeOnce.Do(func() {
+		c.sendPacket(&packet{
+			kind:    packetDisconnect,
+			session: c.session,
+		})
+		c.shutdown()
+	})
+}
+
+// shutdown without notifying the peer
+func (c *Connection) shutdown() {
+	select {
+	case <-c.closed:
+		return
+	default:
+	}
+	close(c.closed)
+	if c.onClose != nil {
+		c.onClose(c)
+	}
+}
+
+// PrepareWrite is a noop, writing to a UDP socket does not block
+func (c *Connection) PrepareWrite(writeWait time.Duration) {}
+
+// Write data to the peer reliably
+func (c *Connection) Write(data []byte) error {
+	return c.WriteReliable(data)
+}
+
+// WriteReliable sends data that is retransmitted until acknowledged and delivered in order
+func (c *Connection) WriteReliable(data []byte) error {
+	fragments, err := c.split(data)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.pending)+len(fragments) > maxPending {
+		return ErrCongested
+	}
+
+	for i, fragment := range fragments {
+		encoded := (&packet{
+			kind:          packetReliable,
+			session:       c.session,
+			sequence:      c.reliableSequence,
+			fragmentIndex: uint8(i),
+			fragmentCount: uint8(len(fragments)),
+			payload:       fragment,
+		}).encode()
+		c.pending[c.reliableSequence] = &pendingPacket{
+			data:   encoded,
+			sentAt: time.Now(),
+		}
+		c.reliableSequence++
+		if err := c.sendRaw(encoded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteUnreliable sends data without retransmission, older messages arriving after newer ones are dropped
+func (c *Connection) WriteUnreliable(data []byte) error {
+	fragments, err := c.split(data)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	sequence := c.unreliableSequence
+	c.unreliableSequence++
+	c.mutex.Unlock()
+
+	for i, fragment := range fragments {
+		err := c.sendPacket(&packet{
+			kind:          packetUnreliable,
+			session:       c.session,
+			sequence:      sequence,
+			fragmentIndex: uint8(i),
+			fragmentCount: uint8(len(fragments)),
+			payload:       fragment,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrepareRead sets the maximum message size and the time the peer may stay silent
+func (c *Connection) PrepareRead(maxMessageSize int64, pongWait time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.maxMessageSize = maxMessageSize
+	c.pongWait = pongWait
+	c.lastReceived = time.Now()
+}
+
+// Read the next message from the peer
+func (c *Connection) Read() ([]byte, error) {
+	for {
+		c.mutex.Lock()
+		pongWait := c.pongWait
+		deadline := c.lastReceived.Add(pongWait)
+		c.mutex.Unlock()
+
+		if pongWait == 0 {
+			select {
+			case message := <-c.incoming:
+				return message, nil
+			case <-c.closed:
+				return nil, ErrClosed
+			}
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, ErrTimeout
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case message := <-c.incoming:
+			timer.Stop()
+			return message, nil
+		case <-c.closed:
+			timer.Stop()
+			return nil, ErrClosed
+		case <-timer.C:
+			// deadline may have been extended in the meantime
+		}
+	}
+}
+
+func (c *Connection) split(data []byte) ([][]byte, error) {
+	size := c.mtu - headerSize - dataHeaderSize
+	count := (len(data) + size - 1) / size
+	if count == 0 {
+		count = 1
+	}
+	if count > 255 {
+		return nil, ErrMessageSize
+	}
+
+	fragments := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(data) {
+			end = len(data)
+		}
+		fragments = append(fragments, data[i*size:end])
+	}
+	return fragments, nil
+}
+
+func (c *Connection) sendPacket(p *packet) error {
+	return c.sendRaw(p.encode())
+}
+
+func (c *Connection) sendRaw(data []byte) error {
+	select {
+	case <-c.closed:
+		return ErrClosed
+	default:
+	}
+	return c.send(data)
+}
+
+// handle a packet received for this session
+func (c *Connection) handle(p *packet) {
+	c.mutex.Lock()
+	c.lastReceived = time.Now()
+	c.mutex.Unlock()
+
+	switch p.kind {
+	case packetKeepalive:
+		if len(p.payload) > 0 && p.payload[0] == keepaliveRequest {
+			c.sendPacket(&packet{
+				kind:    packetKeepalive,
+				session: c.session,
+				payload: []byte{keepaliveReply},
+			})
+		}
+	case packetDisconnect:
+		c.closeOnce.Do(c.shutdown)
+	case packetAck:
+		c.mutex.Lock()
+		delete(c.pending, p.sequence)
+		c.mutex.Unlock()
+	case packetReliable:
+		if !c.handleReliable(p) {
+			return
+		}
+		c.sendPacket(&packet{
+			kind:     packetAck,
+			session:  c.session,
+			sequence: p.sequence,
+		})
+	case packetUnreliable:
+		c.handleUnreliable(p)
+	}
+}
+
+// handleReliable buffers a packet until all packets before were received, returns false if the
+// packet is outside the receive window and must not be acknowledged
+func (c *Connection) handleReliable(p *packet) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if sequenceGreater(c.expected, p.sequence) {
+		// duplicate of an already delivered packet, the ack got lost
+		return true
+	}
+	if p.sequence-c.expected >= receiveWindow {
+		return false
+	}
+	c.received[p.sequence] = p
+
+	for {
+		next, ok := c.received[c.expected]
+		if !ok {
+			return true
+		}
+		delete(c.received, c.expected)
+		c.expected++
+
+		if next.fragmentIndex != uint8(len(c.fragments)) {
+			// fragments are sent with consecutive sequences, a mismatch means a broken peer
+			c.fragments = nil
+			continue
+		}
+		c.fragments = append(c.fragments, next.payload)
+		if len(c.fragments) == int(next.fragmentCount) {
+			c.deliver(join(c.fragments))
+			c.fragments = nil
+		}
+	}
+}
+
+func (c *Connection) handleUnreliable(p *packet) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.hasDelivered && !sequenceGreater(p.sequence, c.delivered) {
+		return
+	}
+
+	a, ok := c.assemblies[p.sequence]
+	if !ok {
+		if len(c.assemblies) >= maxAssemblies {
+			c.dropOldestAssembly()
+		}
+		a = &assembly{fragments: make([][]byte, p.fragmentCount)}
+		c.assemblies[p.sequence] = a
+	}
+	if int(p.fragmentIndex) >= len(a.fragments) || a.fragments[p.fragmentIndex] != nil {
+		return
+	}
+	a.fragments[p.fragmentIndex] = p.payload
+	a.received++
+	if a.received < len(a.fragments) {
+		return
+	}
+
+	c.delivered = p.sequence
+	c.hasDelivered = true
+	for sequence := range c.assemblies {
+		if !sequenceGreater(sequence, c.delivered) {
+			delete(c.assemblies, sequence)
+		}
+	}
+	c.deliver(join(a.fragments))
+}
+
+func (c *Connection) dropOldestAssembly() {
+	var oldest uint16
+	first := true
+	for sequence := range c.assemblies {
+		if first || sequenceGreater(oldest, sequence) {
+			oldest = sequence
+			first = false
+		}
+	}
+	delete(c.assemblies, oldest)
+}
+
+// deliver a reassembled message, the caller holds the mutex
+func (c *Connection) deliver(message []byte) {
+	if c.maxMessageSize > 0 && int64(len(message)) > c.maxMessageSize {
+		return
+	}
+	select {
+	case c.incoming <- message:
+	default:
+		// the reader can not keep up, the session is dropped like a websocket exceeding its buffers
+		go c.Close(0, false)
+	}
+}
+
+// resend unacknowledged reliable packets until the connection closes
+func (c *Connection) resend() {
+	ticker := time.NewTicker(resendInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case now := <-ticker.C:
+			lost := false
+			c.mutex.Lock()
+			for _, p := range c.pending {
+				if now.Sub(p.sentAt) < resendTimeout {
+					continue
+				}
+				if p.resends >= maxResends {
+					lost = true
+					break
+				}
+				p.resends++
+				p.sentAt = now
+				c.send(p.data)
+			}
+			c.mutex.Unlock()
+			if lost {
+				c.closeOnce.Do(c.shutdown)
+				return
+			}
+		}
+	}
+}
+
+func join(fragments [][]byte) []byte {
+	size := 0
+	for _, fragment := range fragments {
+		size += len(fragment)
+	}
+	message := make([]byte, 0, size)
+	for _, fragment := range fragments {
+		message = append(message, fragment...)
+	}
+	return message
+}
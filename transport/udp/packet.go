@@ -0,0 +1,125 @@
+package udp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// protocolID identifies triebwerk UDP packets, packets with a different first byte are dropped
+const protocolID = 0x54
+
+// DefaultMTU is the maximum size of a datagram, chosen to stay below the IPv6 minimum MTU
+const DefaultMTU = 1200
+
+type packetType uint8
+
+const (
+	packetConnect packetType = iota
+	packetAccept
+	packetKeepalive
+	packetDisconnect
+	packetUnreliable
+	packetReliable
+	packetAck
+)
+
+const (
+	// magic + type + session id
+	headerSize = 6
+	// sequence + fragment index + fragment count
+	dataHeaderSize = 4
+	// size of the nonce a client sends with its connect request
+	nonceSize = 4
+	// keepalive flag for requests that have to be answered
+	keepaliveRequest = 0
+	keepaliveReply   = 1
+)
+
+var errInvalidPacket = errors.New("invalid packet")
+
+// packet is a decoded datagram
+type packet struct {
+	kind          packetType
+	session       uint32
+	sequence      uint16
+	fragmentIndex uint8
+	fragmentCount uint8
+	payload       []byte
+}
+
+func (p *packet) encode() []byte {
+	size := headerSize + len(p.payload)
+	if p.isData() {
+		size += dataHeaderSize
+	}
+	if p.kind == packetAck {
+		size += 2
+	}
+
+	buf := make([]byte, headerSize, size)
+	buf[0] = protocolID
+	buf[1] = byte(p.kind)
+	binary.LittleEndian.PutUint32(buf[2:], p.session)
+
+	switch {
+	case p.isData():
+		sequence := make([]byte, 2)
+		binary.LittleEndian.PutUint16(sequence, p.sequence)
+		buf = append(buf, sequence...)
+		buf = append(buf, p.fragmentIndex, p.fragmentCount)
+	case p.kind == packetAck:
+		sequence := make([]byte, 2)
+		binary.LittleEndian.PutUint16(sequence, p.sequence)
+		buf = append(buf, sequence...)
+	}
+
+	return append(buf, p.payload...)
+}
+
+func (p *packet) isData() bool {
+	return p.kind == packetUnreliable || p.kind == packetReliable
+}
+
+func decodePacket(data []byte) (*packet, error) {
+	if len(data) < headerSize || data[0] != protocolID {
+		return nil, errInvalidPacket
+	}
+
+	p := &packet{
+		kind:    packetType(data[1]),
+		session: binary.LittleEndian.Uint32(data[2:]),
+	}
+	body := data[headerSize:]
+
+	switch {
+	case p.isData():
+		if len(body) < dataHeaderSize {
+			return nil, errInvalidPacket
+		}
+		p.sequence = binary.LittleEndian.Uint16(body)
+		p.fragmentIndex = body[2]
+		p.fragmentCount = body[3]
+		if p.fragmentCount == 0 || p.fragmentIndex >= p.fragmentCount {
+			return nil, errInvalidPacket
+		}
+		body = body[dataHeaderSize:]
+	case p.kind == packetAck:
+		if len(body) < 2 {
+			return nil, errInvalidPacket
+		}
+		p.sequence = binary.LittleEndian.Uint16(body)
+		body = body[2:]
+	case p.kind > packetAck:
+		return nil, errInvalidPacket
+	}
+
+	// the read buffer is reused, payload has to be copied
+	p.payload = make([]byte, len(body))
+	copy(p.payload, body)
+	return p, nil
+}
+
+// sequenceGreater compares sequence numbers that wrap around
+func sequenceGreater(a uint16, b uint16) bool {
+	return int16(a-b) > 0
+}
@@ -0,0 +1,255 @@
+package udp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+)
+
+// connectRetryInterval in which a client repeats its connect request until accepted
+const connectRetryInterval = 250 * time.Millisecond
+
+// Transport represents the UDP context
+type Transport struct {
+	register   func(conn model.Connection)
+	unregister func(conn model.Connection)
+	port       int
+	address    string
+	mtu        int
+
+	socket *net.UDPConn
+	err    error
+
+	mutex    sync.RWMutex
+	sessions map[uint32]*Connection
+	peers    map[string]*Connection
+}
+
+// NewTransport creates the UDP context
+func NewTransport(address string, port int, mtu int) *Transport {
+	if mtu <= headerSize+dataHeaderSize {
+		mtu = DefaultMTU
+	}
+	return &Transport{
+		address:  address,
+		port:     port,
+		mtu:      mtu,
+		sessions: make(map[uint32]*Connection),
+		peers:    make(map[string]*Connection),
+	}
+}
+
+// GetAddress ...
+func (t *Transport) GetAddress() string {
+	return strings.Join([]string{t.address, strconv.Itoa(t.port)}, ":")
+}
+
+// RegisterNewConnHandler is a callback for new connections
+func (t *Transport) RegisterNewConnHandler(register func(conn model.Connection)) {
+	t.register = register
+}
+
+// UnregisterConnHandler is a callback for closed connections
+func (t *Transport) UnregisterConnHandler(unregister func(conn model.Connection)) {
+	t.unregister = unregister
+}
+
+// Unregister callback
+func (t *Transport) Unregister(conn model.Connection) {
+	t.unregister(conn)
+}
+
+// Init opens the UDP socket
+func (t *Transport) Init() {
+	t.socket, t.err = net.ListenUDP("udp", &net.UDPAddr{Port: t.port})
+	if t.err != nil {
+		return
+	}
+	t.port = t.socket.LocalAddr().(*net.UDPAddr).Port
+}
+
+// Run reads datagrams and dispatches them to their sessions
+func (t *Transport) Run() error {
+	if t.err != nil {
+		return t.err
+	}
+	if t.socket == nil {
+		return errors.New("udp: transport not initialized")
+	}
+
+	log.Printf("Starting Triebwerk UDP Server on %s...", t.GetAddress())
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := t.socket.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+		p, err := decodePacket(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		if p.kind == packetConnect {
+			t.accept(addr, p)
+			continue
+		}
+
+		t.mutex.RLock()
+		conn, ok := t.sessions[p.session]
+		t.mutex.RUnlock()
+		// packets have to come from the address the session was created from
+		if !ok || conn.addr.String() != addr.String() {
+			continue
+		}
+		conn.handle(p)
+	}
+}
+
+// accept a connect request, repeated requests of a peer are answered with its existing session.
+// The request contains a nonce followed by the URL encoded connection params.
+func (t *Transport) accept(addr *net.UDPAddr, p *packet) {
+	if len(p.payload) < nonceSize {
+		return
+	}
+	params, err := url.ParseQuery(string(p.payload[nonceSize:]))
+	if err != nil {
+		return
+	}
+
+	t.mutex.Lock()
+	conn, ok := t.peers[addr.String()]
+	if !ok {
+		session, err := newSessionID()
+		for err == nil && t.sessions[session] != nil {
+			session, err = newSessionID()
+		}
+		if err != nil {
+			t.mutex.Unlock()
+			log.Printf("UDP: Could not create session for %s: %s", addr, err)
+			return
+		}
+
+		conn = newConnection(session, addr, t.mtu, func(data []byte) error {
+			_, err := t.socket.WriteToUDP(data, addr)
+			return err
+		})
+		conn.onClose = t.remove
+		conn.params = params
+		t.sessions[session] = conn
+		t.peers[addr.String()] = conn
+	}
+	t.mutex.Unlock()
+
+	// the accept echoes the nonce so the client can match it to its request
+	conn.sendPacket(&packet{
+		kind:    packetAccept,
+		session: conn.session,
+		payload: p.payload[:nonceSize],
+	})
+	if !ok {
+		go t.register(conn)
+	}
+}
+
+func (t *Transport) remove(conn *Connection) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.sessions, conn.session)
+	delete(t.peers, conn.addr.String())
+}
+
+// Dial opens a session with a UDP server passing the given params, used by native clients
+func Dial(address string, params url.Values, mtu int, timeout time.Duration) (*Connection, error) {
+	if mtu <= headerSize+dataHeaderSize {
+		mtu = DefaultMTU
+	}
+	remote, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	socket, err := net.DialUDP("udp", nil, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		socket.Close()
+		return nil, err
+	}
+	request := (&packet{kind: packetConnect, payload: append(nonce, params.Encode()...)}).encode()
+
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 65536)
+	var accepted *packet
+	for accepted == nil {
+		if time.Now().After(deadline) {
+			socket.Close()
+			return nil, fmt.Errorf("udp: no answer from %s within %s", address, timeout)
+		}
+		if _, err := socket.Write(request); err != nil {
+			socket.Close()
+			return nil, err
+		}
+		socket.SetReadDeadline(time.Now().Add(connectRetryInterval))
+		for {
+			n, err := socket.Read(buf)
+			if err != nil {
+				break
+			}
+			p, err := decodePacket(buf[:n])
+			if err == nil && p.kind == packetAccept && string(p.payload) == string(nonce) {
+				accepted = p
+				break
+			}
+		}
+	}
+	socket.SetReadDeadline(time.Time{})
+
+	conn := newConnection(accepted.session, remote, mtu, func(data []byte) error {
+		_, err := socket.Write(data)
+		return err
+	})
+	conn.onClose = func(c *Connection) {
+		socket.Close()
+	}
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, err := socket.Read(buf)
+			if err != nil {
+				conn.closeOnce.Do(conn.shutdown)
+				return
+			}
+			p, err := decodePacket(buf[:n])
+			if err != nil || p.session != conn.session {
+				continue
+			}
+			conn.handle(p)
+		}
+	}()
+	return conn, nil
+}
+
+func newSessionID() (uint32, error) {
+	buf := make([]byte, 4)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return 0, err
+		}
+		// session 0 is reserved for connect requests
+		if session := binary.LittleEndian.Uint32(buf); session != 0 {
+			return session, nil
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package udp
+
+import (
+	"bytes"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/awdng/triebwerk/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func startTransport(t *testing.T) (*Transport, chan model.Connection) {
+	connections := make(chan model.Connection, 1)
+	transport := NewTransport("127.0.0.1", 0, 64)
+	transport.RegisterNewConnHandler(func(conn model.Connection) {
+		connections <- conn
+	})
+	transport.Init()
+	go transport.Run()
+	return transport, connections
+}
+
+func TestSessionMessages(t *testing.T) {
+	transport, connections := startTransport(t)
+	defer transport.socket.Close()
+
+	client, err := Dial(transport.GetAddress(), url.Values{"resume": []string{"token"}}, 64, time.Second)
+	assert.Nil(t, err)
+	server := (<-connections).(*Connection)
+	assert.Equal(t, client.Session(), server.Session())
+	assert.Equal(t, "token", server.Params().Get("resume"))
+
+	server.PrepareRead(1024, time.Second)
+	client.PrepareRead(1024, time.Second)
+
+	// messages larger than the MTU are fragmented and reassembled
+	large := bytes.Repeat([]byte{1, 2, 3, 4, 5}, 50)
+	assert.Nil(t, client.WriteReliable(large))
+	assert.Nil(t, client.WriteReliable([]byte{6}))
+	message, err := server.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, large, message)
+	message, err = server.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{6}, message)
+
+	assert.Nil(t, server.WriteUnreliable(large))
+	message, err = client.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, large, message)
+
+	client.Close(0, true)
+	_, err = server.Read()
+	assert.Equal(t, ErrClosed, err)
+}
+
+func TestSequenceWrapAround(t *testing.T) {
+	assert.Equal(t, true, sequenceGreater(1, 0))
+	assert.Equal(t, true, sequenceGreater(0, 65535))
+	assert.Equal(t, false, sequenceGreater(65535, 0))
+}
+
+func TestReceiveWindow(t *testing.T) {
+	acks := []uint16{}
+	c := newConnection(1, &net.UDPAddr{}, DefaultMTU, func(data []byte) error {
+		if p, err := decodePacket(data); err == nil && p.kind == packetAck {
+			acks = append(acks, p.sequence)
+		}
+		return nil
+	})
+	defer c.Close(0, false)
+	reliable := func(sequence uint16) *packet {
+		return &packet{kind: packetReliable, sequence: sequence, fragmentCount: 1, payload: []byte{byte(sequence)}}
+	}
+
+	// packets beyond the window are dropped without ack, the peer sends them again
+	c.handle(reliable(receiveWindow))
+	c.handle(reliable(receiveWindow - 1))
+	assert.Equal(t, []uint16{receiveWindow - 1}, acks)
+	assert.Equal(t, 1, len(c.received))
+
+	for sequence := uint16(0); sequence < receiveWindow-1; sequence++ {
+		c.handle(reliable(sequence))
+	}
+	assert.Equal(t, 0, len(c.received))
+	assert.Equal(t, uint16(receiveWindow), c.expected)
+}
+
+func TestReadTimeout(t *testing.T) {
+	transport, connections := startTransport(t)
+	defer transport.socket.Close()
+
+	client, err := Dial(transport.GetAddress(), nil, 0, time.Second)
+	assert.Nil(t, err)
+	defer client.Close(0, false)
+	server := (<-connections).(*Connection)
+
+	server.PrepareRead(1024, 50*time.Millisecond)
+	_, err = server.Read()
+	assert.Equal(t, ErrTimeout, err)
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +16,7 @@ import (
 
 // Transport represents the websocket context
 type Transport struct {
+	mux        *http.ServeMux
 	upgrader   websocket.Upgrader
 	register   func(conn model.Connection)
 	unregister func(conn model.Connection)
@@ -25,6 +27,7 @@ type Transport struct {
 // NewTransport creates the websocket context
 func NewTransport(address string, port int) *Transport {
 	return &Transport{
+		mux:     http.NewServeMux(),
 		address: address,
 		port:    port,
 		upgrader: websocket.Upgrader{
@@ -57,15 +60,20 @@ func (t *Transport) Unregister(conn model.Connection) {
 	t.unregister(conn)
 }
 
+// HandleFunc serves an additional http endpoint alongside the websocket endpoint
+func (t *Transport) HandleFunc(pattern string, handler http.HandlerFunc) {
+	t.mux.HandleFunc(pattern, handler)
+}
+
 // Init ...
 func (t *Transport) Init() {
-	http.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+	t.mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
 		ws, err := t.upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			log.Println(err)
 			return
 		}
-		conn := NewConnection(ws)
+		conn := NewConnection(ws, r.URL.Query())
 		t.register(conn)
 	})
 }
@@ -79,21 +87,28 @@ func (t *Transport) Run() error {
 	t.port = listener.Addr().(*net.TCPAddr).Port
 
 	log.Printf("Starting Triebwerk Websocket Server on %s...", t.GetAddress())
-	return http.Serve(listener, nil)
+	return http.Serve(listener, t.mux)
 }
 
 // Connection represents a websocket connection
 type Connection struct {
-	conn *websocket.Conn
+	conn   *websocket.Conn
+	params url.Values
 }
 
 // NewConnection creates a new connection
-func NewConnection(conn *websocket.Conn) *Connection {
+func NewConnection(conn *websocket.Conn, params url.Values) *Connection {
 	return &Connection{
-		conn: conn,
+		conn:   conn,
+		params: params,
 	}
 }
 
+// Params of the URL query the websocket was opened with
+func (c *Connection) Params() url.Values {
+	return c.params
+}
+
 // Identifier of the connection
 func (c *Connection) Identifier() string {
 	return fmt.Sprintf("%s - %s", c.conn.RemoteAddr().Network(), c.conn.RemoteAddr().String())
@@ -1,6 +1,8 @@
 package triebwerk
 
 import (
+	"time"
+
 	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go"
 )
@@ -11,6 +13,49 @@ type Config struct {
 	MasterServerGRPC string `envconfig:"MASTERSERVER_GRPC" required:"false" default:"localhost:8081"`
 	Region           string `envconfig:"REGION" required:"true" default:"EU"`
 	Port             int    `envconfig:"PORT" required:"false" default:"80"`
+	UDPPort          int    `envconfig:"UDP_PORT" required:"false"`
+	UDPMTU           int    `envconfig:"UDP_MTU" required:"false" default:"1200"`
+
+	// CasterPort serves the game delayed by CasterDelay for streams, disabled if not set
+	CasterPort  int           `envconfig:"CASTER_PORT" required:"false"`
+	CasterDelay time.Duration `envconfig:"CASTER_DELAY" required:"false" default:"30s"`
+
+	// AdminAddress serves the metrics at /debug/vars, eg. localhost:6060. Disabled if not set,
+	// it must not be reachable by players
+	AdminAddress string `envconfig:"ADMIN_ADDRESS" required:"false"`
+
+	// ReplayDir replays of all matches are saved to, recording is disabled if not set
+	ReplayDir string `envconfig:"REPLAY_DIR" required:"false"`
+
+	// SessionGracePeriod a disconnected player stays in the game and can resume its session
+	SessionGracePeriod time.Duration `envconfig:"SESSION_GRACE_PERIOD" required:"false" default:"30s"`
+
+	// BotFill fills games with bots up to this number of players while humans are connected, disabled if 0
+	BotFill       int    `envconfig:"BOT_FILL" required:"false"`
+	BotDifficulty string `envconfig:"BOT_DIFFICULTY" required:"false" default:"normal"`
+
+	// AuthProvider verifies player tokens: master, firebase, jwt or anonymous (development only)
+	AuthProvider string `envconfig:"AUTH_PROVIDER" required:"false" default:"master"`
+	// AuthJWTSecret verifies HS256 tokens, AuthJWTPublicKey is the path of a PEM key for RS256 tokens
+	AuthJWTSecret    string `envconfig:"AUTH_JWT_SECRET" required:"false"`
+	AuthJWTPublicKey string `envconfig:"AUTH_JWT_PUBLIC_KEY" required:"false"`
+	AuthJWTIssuer    string `envconfig:"AUTH_JWT_ISSUER" required:"false"`
+	// AuthTimeout a connection has to authenticate in before it is closed, 0 disables the timeout
+	AuthTimeout time.Duration `envconfig:"AUTH_TIMEOUT" required:"false" default:"10s"`
+
+	// ReliableQueueSize of messages waiting for a client before it is disconnected,
+	// UnreliableQueueSize of state updates waiting for a client before the oldest is dropped
+	ReliableQueueSize   int `envconfig:"RELIABLE_QUEUE_SIZE" required:"false" default:"100"`
+	UnreliableQueueSize int `envconfig:"UNRELIABLE_QUEUE_SIZE" required:"false" default:"3"`
+
+	// InfraQueueSize calls to the master server and auth providers can wait in, further calls are dropped
+	InfraQueueSize int `envconfig:"INFRA_QUEUE_SIZE" required:"false" default:"64"`
+	// InfraWorkers run calls to the master server and auth providers concurrently
+	InfraWorkers int `envconfig:"INFRA_WORKERS" required:"false" default:"4"`
+
+	// Storage keeps player profiles and highscores: memory, file (a JSON file at StoragePath) or firestore
+	Storage     string `envconfig:"STORAGE" required:"false" default:"memory"`
+	StoragePath string `envconfig:"STORAGE_PATH" required:"false" default:"triebwerk.json"`
 }
 
 // Firebase ...